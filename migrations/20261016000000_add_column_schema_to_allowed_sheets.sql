@@ -0,0 +1,18 @@
+-- migrate:up
+-- =============================================================================
+-- Add Column Schema to Allowed Sheets
+-- =============================================================================
+-- Stores a per-sheet column type map used by the worker to coerce values on
+-- read and validate them on write, e.g. {"price": {"type": "number"}}.
+-- Supported types: string, number, integer, boolean, date, datetime, json, list.
+-- When NULL, the worker infers types from a sample of rows.
+-- =============================================================================
+
+ALTER TABLE allowed_sheets
+  ADD COLUMN column_schema JSONB;
+
+COMMENT ON COLUMN allowed_sheets.column_schema IS 'Per-column type definitions keyed by header name';
+
+-- migrate:down
+ALTER TABLE allowed_sheets
+  DROP COLUMN IF EXISTS column_schema;
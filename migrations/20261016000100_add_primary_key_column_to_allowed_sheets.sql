@@ -0,0 +1,16 @@
+-- migrate:up
+-- =============================================================================
+-- Add Primary Key Column to Allowed Sheets
+-- =============================================================================
+-- Names the sheet column whose values uniquely identify a row. Used by the
+-- worker for stable cursor pagination; when NULL the sheet row number is used.
+-- =============================================================================
+
+ALTER TABLE allowed_sheets
+  ADD COLUMN primary_key_column TEXT;
+
+COMMENT ON COLUMN allowed_sheets.primary_key_column IS 'Header of the column that uniquely identifies a row';
+
+-- migrate:down
+ALTER TABLE allowed_sheets
+  DROP COLUMN IF EXISTS primary_key_column;
@@ -0,0 +1,16 @@
+-- migrate:up
+-- =============================================================================
+-- Add Search Columns to Allowed Sheets
+-- =============================================================================
+-- Limits the worker's full-text search (q=) to a subset of columns. An empty
+-- array searches every column.
+-- =============================================================================
+
+ALTER TABLE allowed_sheets
+  ADD COLUMN search_columns TEXT[] DEFAULT '{}' NOT NULL;
+
+COMMENT ON COLUMN allowed_sheets.search_columns IS 'Columns searched by q=; empty means all columns';
+
+-- migrate:down
+ALTER TABLE allowed_sheets
+  DROP COLUMN IF EXISTS search_columns;
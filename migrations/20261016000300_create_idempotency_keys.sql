@@ -0,0 +1,30 @@
+-- migrate:up
+-- =============================================================================
+-- Create Idempotency Keys
+-- =============================================================================
+-- Stores worker write responses by Idempotency-Key so retried requests are
+-- replayed instead of applied twice. Used when Redis is not configured. A row
+-- without a response_status is a request still in flight.
+-- =============================================================================
+
+CREATE TABLE idempotency_keys (
+  sheet_id UUID NOT NULL REFERENCES allowed_sheets(id) ON DELETE CASCADE,
+  idempotency_key TEXT NOT NULL,
+  fingerprint TEXT NOT NULL,
+  response_status INTEGER,
+  response_headers JSONB,
+  response_body BYTEA,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMPTZ NOT NULL,
+
+  PRIMARY KEY (sheet_id, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+COMMENT ON TABLE idempotency_keys IS 'Stored write responses replayed for repeated Idempotency-Key requests';
+COMMENT ON COLUMN idempotency_keys.idempotency_key IS 'Client key prefixed with a digest of the credential that sent it';
+COMMENT ON COLUMN idempotency_keys.fingerprint IS 'Hash of credential, method, URL and body; a reused key with another fingerprint is rejected';
+
+-- migrate:down
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,24 @@
+-- migrate:up
+-- =============================================================================
+-- Create Column Sequences
+-- =============================================================================
+-- Hands out auto_increment values for generated sheet columns. Reading the
+-- largest ID in the sheet is not enough when writes run concurrently, so the
+-- worker reserves values here, seeded from that largest ID, and two writers
+-- never receive the same one.
+-- =============================================================================
+
+CREATE TABLE column_sequences (
+  sheet_id UUID NOT NULL REFERENCES allowed_sheets(id) ON DELETE CASCADE,
+  collection TEXT NOT NULL,
+  column_name TEXT NOT NULL,
+  last_value BIGINT NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (sheet_id, collection, column_name)
+);
+
+COMMENT ON TABLE column_sequences IS 'Last auto_increment value reserved per sheet, collection and column';
+
+-- migrate:down
+DROP TABLE IF EXISTS column_sequences;
@@ -0,0 +1,16 @@
+-- migrate:up
+-- =============================================================================
+-- Add Strict Fields to Allowed Sheets
+-- =============================================================================
+-- When set, worker writes that send a field the sheet has no column for are
+-- rejected instead of having the field silently dropped.
+-- =============================================================================
+
+ALTER TABLE allowed_sheets
+  ADD COLUMN strict_fields BOOLEAN DEFAULT false NOT NULL;
+
+COMMENT ON COLUMN allowed_sheets.strict_fields IS 'Reject writes with fields that are not sheet columns';
+
+-- migrate:down
+ALTER TABLE allowed_sheets
+  DROP COLUMN IF EXISTS strict_fields;
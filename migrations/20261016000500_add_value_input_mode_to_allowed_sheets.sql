@@ -0,0 +1,19 @@
+-- migrate:up
+-- =============================================================================
+-- Add Value Input Mode to Allowed Sheets
+-- =============================================================================
+-- How the worker writes client values: RAW (stored as-is), USER_ENTERED
+-- (parsed like typed input, so formulas run) or SANITIZED (parsed, but text
+-- starting with = + - @ is stored as text). NULL picks SANITIZED for sheets
+-- with auth_type 'none' and USER_ENTERED otherwise.
+-- =============================================================================
+
+ALTER TABLE allowed_sheets
+  ADD COLUMN value_input_mode TEXT
+  CHECK (value_input_mode IN ('RAW', 'USER_ENTERED', 'SANITIZED'));
+
+COMMENT ON COLUMN allowed_sheets.value_input_mode IS 'RAW, USER_ENTERED or SANITIZED; NULL uses the auth-type default';
+
+-- migrate:down
+ALTER TABLE allowed_sheets
+  DROP COLUMN IF EXISTS value_input_mode;
@@ -0,0 +1,74 @@
+-- migrate:up
+-- =============================================================================
+-- Create API Keys
+-- =============================================================================
+-- Named API keys let a sheet have many credentials, each with its own label,
+-- scopes and optional expiry, so one leaked key can be revoked without
+-- breaking every consumer. A key is accepted in place of the sheet's api_key
+-- path segment or as an Authorization: Bearer token. Only a SHA-256 digest of
+-- the key is stored, with a short visible prefix to identify it. Usage is
+-- still counted against the sheet (api_usage_daily.api_key), with one row per
+-- named key through api_usage_daily.api_key_id for attribution.
+-- =============================================================================
+
+CREATE TABLE api_keys (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  sheet_id UUID NOT NULL REFERENCES allowed_sheets(id) ON DELETE CASCADE,
+  label TEXT NOT NULL,
+  key_digest TEXT NOT NULL UNIQUE,
+  key_prefix TEXT NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{read}',
+  expires_at TIMESTAMPTZ,
+  last_used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_keys_sheet_id ON api_keys(sheet_id);
+
+ALTER TABLE api_usage_daily
+  ADD COLUMN api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_api_usage_daily_api_key_id ON api_usage_daily(api_key_id)
+  WHERE api_key_id IS NOT NULL;
+
+-- One usage row per sheet, named key (or none), day and method
+ALTER TABLE api_usage_daily
+  DROP CONSTRAINT api_usage_daily_api_key_request_date_method_key;
+
+CREATE UNIQUE INDEX idx_api_usage_daily_key_date_method ON api_usage_daily(
+  api_key, (COALESCE(api_key_id, '00000000-0000-0000-0000-000000000000')), request_date, method
+);
+
+COMMENT ON TABLE api_keys IS 'Named API keys of a sheet with per-key scopes and expiry';
+COMMENT ON COLUMN api_keys.scopes IS 'read, write, delete, or scope:collection to limit a scope to one sheet tab';
+COMMENT ON COLUMN api_keys.key_digest IS 'Hex SHA-256 digest of the key';
+COMMENT ON COLUMN api_keys.key_prefix IS 'Leading characters of the key, shown to identify it';
+COMMENT ON COLUMN api_usage_daily.api_key_id IS 'Named API key that made the requests (NULL for the sheet credentials)';
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_api_usage_daily_key_date_method;
+
+-- Fold the per-key rows back into one row per sheet, day and method
+UPDATE api_usage_daily u SET request_count = t.total
+FROM (
+  SELECT MIN(id::text) AS keep, SUM(request_count) AS total
+  FROM api_usage_daily
+  GROUP BY api_key, request_date, method
+) t
+WHERE u.id::text = t.keep;
+
+DELETE FROM api_usage_daily u
+USING (
+  SELECT api_key, request_date, method, MIN(id::text) AS keep
+  FROM api_usage_daily
+  GROUP BY api_key, request_date, method
+) t
+WHERE u.api_key = t.api_key AND u.request_date = t.request_date
+  AND u.method = t.method AND u.id::text <> t.keep;
+
+ALTER TABLE api_usage_daily
+  ADD CONSTRAINT api_usage_daily_api_key_request_date_method_key UNIQUE (api_key, request_date, method);
+DROP INDEX IF EXISTS idx_api_usage_daily_api_key_id;
+ALTER TABLE api_usage_daily DROP COLUMN IF EXISTS api_key_id;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,27 @@
+-- migrate:up
+-- =============================================================================
+-- Hash Bearer Tokens at Rest
+-- =============================================================================
+-- Bearer tokens are stored as a SHA-256 digest plus a short visible prefix
+-- (e.g. gskey_ab12) instead of in plaintext, like named API keys. Tokens
+-- stored before this migration keep their plaintext column until first use,
+-- when the worker replaces it with the digest.
+-- =============================================================================
+
+ALTER TABLE allowed_sheets
+  ADD COLUMN auth_bearer_token_digest TEXT,
+  ADD COLUMN auth_bearer_token_prefix TEXT;
+
+CREATE UNIQUE INDEX idx_allowed_sheets_bearer_token_digest ON allowed_sheets(auth_bearer_token_digest)
+  WHERE auth_bearer_token_digest IS NOT NULL;
+
+COMMENT ON COLUMN allowed_sheets.auth_bearer_token IS 'Plaintext bearer token from before hashing; cleared on first use';
+COMMENT ON COLUMN allowed_sheets.auth_bearer_token_digest IS 'Hex SHA-256 digest of the bearer token';
+
+-- migrate:down
+-- Hashed tokens cannot be restored: sheets that only have a digest need a new
+-- bearer token.
+DROP INDEX IF EXISTS idx_allowed_sheets_bearer_token_digest;
+ALTER TABLE allowed_sheets
+  DROP COLUMN IF EXISTS auth_bearer_token_prefix,
+  DROP COLUMN IF EXISTS auth_bearer_token_digest;
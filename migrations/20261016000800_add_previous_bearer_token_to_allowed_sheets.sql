@@ -0,0 +1,26 @@
+-- migrate:up
+-- =============================================================================
+-- Add Previous Bearer Token to Allowed Sheets
+-- =============================================================================
+-- Rotating a bearer token can keep the old one valid for a grace window so
+-- consumers can switch over without downtime. The previous token is stored as
+-- a digest and prefix like the current one and stops authenticating at
+-- auth_previous_bearer_token_expires_at.
+-- =============================================================================
+
+ALTER TABLE allowed_sheets
+  ADD COLUMN auth_previous_bearer_token_digest TEXT,
+  ADD COLUMN auth_previous_bearer_token_prefix TEXT,
+  ADD COLUMN auth_previous_bearer_token_expires_at TIMESTAMPTZ;
+
+CREATE INDEX idx_allowed_sheets_previous_bearer_token_digest ON allowed_sheets(auth_previous_bearer_token_digest)
+  WHERE auth_previous_bearer_token_digest IS NOT NULL;
+
+COMMENT ON COLUMN allowed_sheets.auth_previous_bearer_token_digest IS 'Digest of the bearer token replaced by the last rotation, valid until the grace window ends';
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_allowed_sheets_previous_bearer_token_digest;
+ALTER TABLE allowed_sheets
+  DROP COLUMN IF EXISTS auth_previous_bearer_token_expires_at,
+  DROP COLUMN IF EXISTS auth_previous_bearer_token_prefix,
+  DROP COLUMN IF EXISTS auth_previous_bearer_token_digest;
@@ -0,0 +1,21 @@
+-- migrate:up
+-- =============================================================================
+-- Add Allowed Origins and Referers to Allowed Sheets
+-- =============================================================================
+-- Restricts which browser origins and HTTP referers may call a sheet through
+-- the worker, so a key embedded in a site cannot be reused from another one.
+-- Origins are "https://example.com" or "https://*.example.com"; referers may
+-- add a path prefix. An empty array allows any origin or referer.
+-- =============================================================================
+
+ALTER TABLE allowed_sheets
+  ADD COLUMN allowed_origins TEXT[] DEFAULT '{}' NOT NULL,
+  ADD COLUMN allowed_referers TEXT[] DEFAULT '{}' NOT NULL;
+
+COMMENT ON COLUMN allowed_sheets.allowed_origins IS 'Origins allowed to call the worker; empty means any origin';
+COMMENT ON COLUMN allowed_sheets.allowed_referers IS 'Referer prefixes required by the worker; empty means any referer';
+
+-- migrate:down
+ALTER TABLE allowed_sheets
+  DROP COLUMN IF EXISTS allowed_referers,
+  DROP COLUMN IF EXISTS allowed_origins;
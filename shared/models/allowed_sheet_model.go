@@ -1,6 +1,7 @@
 package models
 
 import (
+	"crypto/subtle"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,21 +9,80 @@ import (
 )
 
 type AllowedSheet struct {
-	ID                    uuid.UUID      `db:"id" json:"id"`
-	UserID                uuid.UUID      `db:"user_id" json:"user_id"`
-	SheetID               string         `db:"sheet_id" json:"sheet_id"`
-	SheetName             *string        `db:"sheet_name" json:"sheet_name,omitempty"`
-	Description           *string        `db:"description" json:"description,omitempty"`
-	APIKey                *string        `db:"api_key" json:"api_key,omitempty"`
-	IsPublic              bool           `db:"is_public" json:"is_public"`
-	DefaultRange          *string        `db:"default_range" json:"default_range,omitempty"`
-	UseFirstRowAsHeader   bool           `db:"use_first_row_as_header" json:"use_first_row_as_header"`
-	AllowWrite            bool           `db:"allow_write" json:"allow_write"`
-	AllowedMethods        pq.StringArray `db:"allowed_methods" json:"allowed_methods"`
-	AuthType              string         `db:"auth_type" json:"auth_type"`
-	AuthBearerToken       *string        `db:"auth_bearer_token" json:"auth_bearer_token,omitempty"`
-	AuthBasicUsername     *string        `db:"auth_basic_username" json:"auth_basic_username,omitempty"`
-	AuthBasicPasswordHash *string        `db:"auth_basic_password_hash" json:"-"`
-	CreatedAt             time.Time      `db:"created_at" json:"created_at"`
-	UpdatedAt             time.Time      `db:"updated_at" json:"updated_at"`
+	ID                               uuid.UUID      `db:"id" json:"id"`
+	UserID                           uuid.UUID      `db:"user_id" json:"user_id"`
+	SheetID                          string         `db:"sheet_id" json:"sheet_id"`
+	SheetName                        *string        `db:"sheet_name" json:"sheet_name,omitempty"`
+	Description                      *string        `db:"description" json:"description,omitempty"`
+	APIKey                           *string        `db:"api_key" json:"api_key,omitempty"`
+	IsPublic                         bool           `db:"is_public" json:"is_public"`
+	DefaultRange                     *string        `db:"default_range" json:"default_range,omitempty"`
+	UseFirstRowAsHeader              bool           `db:"use_first_row_as_header" json:"use_first_row_as_header"`
+	AllowWrite                       bool           `db:"allow_write" json:"allow_write"`
+	AllowedMethods                   pq.StringArray `db:"allowed_methods" json:"allowed_methods"`
+	AuthType                         string         `db:"auth_type" json:"auth_type"`
+	AuthBearerToken                  *string        `db:"auth_bearer_token" json:"-"` // plaintext from before hashing
+	AuthBearerTokenDigest            *string        `db:"auth_bearer_token_digest" json:"-"`
+	AuthBearerTokenPrefix            *string        `db:"auth_bearer_token_prefix" json:"auth_bearer_token_prefix,omitempty"`
+	AuthPreviousBearerTokenDigest    *string        `db:"auth_previous_bearer_token_digest" json:"-"` // replaced by the last rotation
+	AuthPreviousBearerTokenPrefix    *string        `db:"auth_previous_bearer_token_prefix" json:"auth_previous_bearer_token_prefix,omitempty"`
+	AuthPreviousBearerTokenExpiresAt *time.Time     `db:"auth_previous_bearer_token_expires_at" json:"auth_previous_bearer_token_expires_at,omitempty"`
+	AuthBasicUsername                *string        `db:"auth_basic_username" json:"auth_basic_username,omitempty"`
+	AuthBasicPasswordHash            *string        `db:"auth_basic_password_hash" json:"-"`
+	ColumnSchema                     ColumnSchema   `db:"column_schema" json:"column_schema,omitempty"`
+	PrimaryKeyColumn                 *string        `db:"primary_key_column" json:"primary_key_column,omitempty"`
+	SearchColumns                    pq.StringArray `db:"search_columns" json:"search_columns"`
+	AllowedOrigins                   pq.StringArray `db:"allowed_origins" json:"allowed_origins"`
+	AllowedReferers                  pq.StringArray `db:"allowed_referers" json:"allowed_referers"`
+	StrictFields                     bool           `db:"strict_fields" json:"strict_fields"`
+	ValueInputMode                   *string        `db:"value_input_mode" json:"value_input_mode,omitempty"`
+	CreatedAt                        time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt                        time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// Value input modes for worker writes
+const (
+	InputModeRaw         = "RAW"          // values are stored exactly as sent
+	InputModeUserEntered = "USER_ENTERED" // values are parsed as if typed, formulas included
+	InputModeSanitized   = "SANITIZED"    // parsed, but text starting with = + - @ stays text
+)
+
+// IsValidInputMode reports whether mode is a supported value input mode
+func IsValidInputMode(mode string) bool {
+	return mode == InputModeRaw || mode == InputModeUserEntered || mode == InputModeSanitized
+}
+
+// InputMode returns how worker writes treat client values. Unless configured,
+// public sheets (auth_type none) are sanitized so anonymous writers cannot
+// inject formulas.
+func (s AllowedSheet) InputMode() string {
+	if s.ValueInputMode != nil && *s.ValueInputMode != "" {
+		return *s.ValueInputMode
+	}
+	if s.AuthType == "none" {
+		return InputModeSanitized
+	}
+	return InputModeUserEntered
+}
+
+// AcceptsBearerToken reports whether a bearer token digest is the sheet's
+// current token, or the one replaced by the last rotation while its grace
+// window is still open at now
+func (s AllowedSheet) AcceptsBearerToken(digest string, now time.Time) bool {
+	if digestEqual(s.AuthBearerTokenDigest, digest) {
+		return true
+	}
+	return digestEqual(s.AuthPreviousBearerTokenDigest, digest) &&
+		s.AuthPreviousBearerTokenExpiresAt != nil && now.Before(*s.AuthPreviousBearerTokenExpiresAt)
+}
+
+// digestEqual compares a stored digest with a computed one in constant time
+func digestEqual(stored *string, digest string) bool {
+	return stored != nil && subtle.ConstantTimeCompare([]byte(*stored), []byte(digest)) == 1
+}
+
+// IsPreviousBearerToken reports whether a bearer token digest is the one
+// replaced by the last rotation rather than the current token
+func (s AllowedSheet) IsPreviousBearerToken(digest string) bool {
+	return digestEqual(s.AuthPreviousBearerTokenDigest, digest) && !digestEqual(s.AuthBearerTokenDigest, digest)
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcceptsBearerToken(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	current, previous := "digest-current", "digest-previous"
+	open, closed := now.Add(time.Hour), now.Add(-time.Second)
+
+	tests := []struct {
+		name         string
+		previousEnds *time.Time
+		digest       string
+		want         bool
+		wantPrevious bool
+	}{
+		{"current token", &open, current, true, false},
+		{"previous token in grace window", &open, previous, true, true},
+		{"previous token after grace window", &closed, previous, false, true},
+		{"previous token without expiry", nil, previous, false, true},
+		{"unknown token", &open, "digest-other", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet := AllowedSheet{
+				AuthBearerTokenDigest:            &current,
+				AuthPreviousBearerTokenDigest:    &previous,
+				AuthPreviousBearerTokenExpiresAt: tt.previousEnds,
+			}
+			if got := sheet.AcceptsBearerToken(tt.digest, now); got != tt.want {
+				t.Errorf("AcceptsBearerToken = %v, want %v", got, tt.want)
+			}
+			if got := sheet.IsPreviousBearerToken(tt.digest); got != tt.wantPrevious {
+				t.Errorf("IsPreviousBearerToken = %v, want %v", got, tt.wantPrevious)
+			}
+		})
+	}
+
+	if (AllowedSheet{}).AcceptsBearerToken("", now) {
+		t.Error("a sheet without a token accepted an empty digest")
+	}
+}
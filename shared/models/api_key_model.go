@@ -0,0 +1,68 @@
+package models
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// APIKey is a named credential of a sheet. Only the digest of the key is
+// stored; the key itself is returned once, when it is created.
+type APIKey struct {
+	ID         uuid.UUID      `db:"id" json:"id"`
+	SheetID    uuid.UUID      `db:"sheet_id" json:"sheet_id"`
+	Label      string         `db:"label" json:"label"`
+	KeyDigest  string         `db:"key_digest" json:"-"`
+	KeyPrefix  string         `db:"key_prefix" json:"key_prefix"`
+	Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+	ExpiresAt  *time.Time     `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `db:"last_used_at" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// API key scopes. A scope may be limited to one collection (sheet tab) as
+// "<scope>:<collection>", e.g. "write:Orders".
+const (
+	ScopeRead   = "read"   // GET requests
+	ScopeWrite  = "write"  // POST, PUT and PATCH requests
+	ScopeDelete = "delete" // DELETE requests
+)
+
+// IsValidScope reports whether scope is a known scope, optionally limited to a collection
+func IsValidScope(scope string) bool {
+	name, collection, limited := strings.Cut(scope, ":")
+	if limited && collection == "" {
+		return false
+	}
+	return name == ScopeRead || name == ScopeWrite || name == ScopeDelete
+}
+
+// ScopeForMethod returns the scope an HTTP method needs
+func ScopeForMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return ScopeRead
+	case http.MethodDelete:
+		return ScopeDelete
+	}
+	return ScopeWrite
+}
+
+// IsExpired reports whether the key has expired at t
+func (k APIKey) IsExpired(t time.Time) bool {
+	return k.ExpiresAt != nil && !t.Before(*k.ExpiresAt)
+}
+
+// Allows reports whether the key grants scope on collection
+func (k APIKey) Allows(scope, collection string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == scope+":"+collection {
+			return true
+		}
+	}
+	return false
+}
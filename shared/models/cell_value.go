@@ -0,0 +1,186 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateTimeLayouts are the formats recognised as a date with a time of day
+var dateTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"1/2/2006 15:04:05",
+}
+
+// dateOnlyLayouts are the formats recognised as a calendar date
+var dateOnlyLayouts = []string{
+	"2006-01-02",
+	"1/2/2006",
+}
+
+// CellValue validates a value sent by a client, or a column default, and
+// converts it to what is written to the sheet for the column type
+func (t ColumnType) CellValue(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch t {
+	case ColumnNumber:
+		if f, ok := NumericValue(value); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("expected a number")
+	case ColumnInteger:
+		if f, ok := NumericValue(value); ok && f == math.Trunc(f) {
+			return int64(f), nil
+		}
+		return nil, fmt.Errorf("expected an integer")
+	case ColumnBoolean:
+		if b, ok := BooleanValue(value); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean")
+	case ColumnDate:
+		if d, ok := DateValue(value); ok {
+			return d.Format("2006-01-02"), nil
+		}
+		return nil, fmt.Errorf("expected a date (YYYY-MM-DD)")
+	case ColumnDatetime:
+		if d, ok := DateValue(value); ok {
+			return d.Format(time.RFC3339), nil
+		}
+		return nil, fmt.Errorf("expected a datetime (RFC 3339)")
+	case ColumnJSON:
+		if s, ok := value.(string); ok && json.Valid([]byte(s)) {
+			return s, nil
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected a JSON value")
+		}
+		return string(b), nil
+	case ColumnList:
+		switch v := value.(type) {
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					items = append(items, s)
+				} else {
+					items = append(items, fmt.Sprintf("%v", item))
+				}
+			}
+			return strings.Join(items, ", "), nil
+		case string:
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected an array or comma-separated string")
+	default:
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("expected a string")
+		}
+		return value, nil
+	}
+}
+
+// groupedNumber matches a number written with comma thousands separators, as
+// Sheets displays numbers formatted with grouping (e.g. "1,234.5")
+var groupedNumber = regexp.MustCompile(`^[-+]?[0-9]{1,3}(,[0-9]{3})+(\.[0-9]+)?$`)
+
+// NumericValue returns the value as a float64 if it is a number or numeric
+// string, including one with thousands separators
+func NumericValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		s := strings.TrimSpace(n)
+		if s == "" {
+			return 0, false
+		}
+		if groupedNumber.MatchString(s) {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// BooleanValue parses booleans as stored by Sheets (TRUE/FALSE) and common aliases
+func BooleanValue(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "1":
+			return true, true
+		case "false", "no", "0":
+			return false, true
+		}
+	case float64:
+		if v == 1 || v == 0 {
+			return v == 1, true
+		}
+	}
+	return false, false
+}
+
+// DateValue returns the value as a time if it is a time or a recognised date string
+func DateValue(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		s := strings.TrimSpace(t)
+		if s == "" {
+			return time.Time{}, false
+		}
+		if parsed, ok := ParseDateTime(s); ok {
+			return parsed, true
+		}
+		if parsed, ok := ParseDate(s); ok {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseDateTime parses a date with a time of day in one of the recognised formats
+func ParseDateTime(s string) (time.Time, bool) {
+	return parseWithLayouts(s, dateTimeLayouts)
+}
+
+// ParseDate parses a calendar date in one of the recognised formats
+func ParseDate(s string) (time.Time, bool) {
+	return parseWithLayouts(s, dateOnlyLayouts)
+}
+
+// parseWithLayouts tries each layout in turn and returns the first successful parse
+func parseWithLayouts(s string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
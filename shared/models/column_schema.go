@@ -0,0 +1,158 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ColumnType is the declared type of a sheet column
+type ColumnType string
+
+const (
+	ColumnString   ColumnType = "string"
+	ColumnNumber   ColumnType = "number"
+	ColumnInteger  ColumnType = "integer"
+	ColumnBoolean  ColumnType = "boolean"
+	ColumnDate     ColumnType = "date"
+	ColumnDatetime ColumnType = "datetime"
+	ColumnJSON     ColumnType = "json"
+	ColumnList     ColumnType = "list"
+)
+
+// IsValid returns true if the column type is one of the supported types
+func (t ColumnType) IsValid() bool {
+	switch t {
+	case ColumnString, ColumnNumber, ColumnInteger, ColumnBoolean, ColumnDate, ColumnDatetime, ColumnJSON, ColumnList:
+		return true
+	default:
+		return false
+	}
+}
+
+// ColumnGenerator names how the worker fills a column's value itself
+type ColumnGenerator string
+
+const (
+	GenerateUUID          ColumnGenerator = "uuid"
+	GenerateULID          ColumnGenerator = "ulid"
+	GenerateAutoIncrement ColumnGenerator = "auto_increment"
+	GenerateNowOnCreate   ColumnGenerator = "now_on_create"
+	GenerateNowOnUpdate   ColumnGenerator = "now_on_update"
+)
+
+// IsValid returns true if the generator is one of the supported generators
+func (g ColumnGenerator) IsValid() bool {
+	switch g {
+	case GenerateUUID, GenerateULID, GenerateAutoIncrement, GenerateNowOnCreate, GenerateNowOnUpdate:
+		return true
+	default:
+		return false
+	}
+}
+
+// fitsType reports whether the generator produces values of the column type
+func (g ColumnGenerator) fitsType(t ColumnType) bool {
+	switch g {
+	case GenerateUUID, GenerateULID:
+		return t == ColumnString
+	case GenerateAutoIncrement:
+		return t == ColumnInteger || t == ColumnNumber
+	case GenerateNowOnCreate, GenerateNowOnUpdate:
+		return t == ColumnDatetime || t == ColumnDate || t == ColumnString
+	}
+	return false
+}
+
+// ColumnDef describes a single column of a sheet.
+// Required defaults to true unless the column has a default or a generator.
+type ColumnDef struct {
+	Type     ColumnType      `json:"type"`
+	Generate ColumnGenerator `json:"generate,omitempty"`
+	Required *bool           `json:"required,omitempty"`
+	Default  interface{}     `json:"default,omitempty"`
+}
+
+// IsRequired reports whether inserts must send a value for the column
+func (d ColumnDef) IsRequired() bool {
+	if d.Required != nil {
+		return *d.Required
+	}
+	return d.Default == nil && d.Generate == ""
+}
+
+// ColumnSchema maps a column header to its definition.
+// Stored as JSONB in allowed_sheets.column_schema.
+type ColumnSchema map[string]ColumnDef
+
+// Validate checks that every column has a supported type, that each default
+// is a value of its column's type and that each generator suits its column's type
+func (s ColumnSchema) Validate() error {
+	for name, def := range s {
+		if name == "" {
+			return fmt.Errorf("column name cannot be empty")
+		}
+		if !def.Type.IsValid() {
+			return fmt.Errorf("column %q has unsupported type %q", name, def.Type)
+		}
+		if _, err := def.Type.CellValue(def.Default); err != nil {
+			return fmt.Errorf("column %q: default %v does not fit its type: %v", name, def.Default, err)
+		}
+		if def.Generate == "" {
+			continue
+		}
+		if !def.Generate.IsValid() {
+			return fmt.Errorf("column %q has unsupported generator %q", name, def.Generate)
+		}
+		if !def.Generate.fitsType(def.Type) {
+			return fmt.Errorf("column %q: generator %q cannot fill a %s column", name, def.Generate, def.Type)
+		}
+	}
+	return nil
+}
+
+// HasGenerator reports whether any column uses the generator
+func (s ColumnSchema) HasGenerator(g ColumnGenerator) bool {
+	for _, def := range s {
+		if def.Generate == g {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer for storing the schema as JSONB
+func (s ColumnSchema) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner for reading the schema from JSONB
+func (s *ColumnSchema) Scan(src interface{}) error {
+	if src == nil {
+		*s = nil
+		return nil
+	}
+
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ColumnSchema", src)
+	}
+
+	if len(b) == 0 {
+		*s = nil
+		return nil
+	}
+	return json.Unmarshal(b, s)
+}
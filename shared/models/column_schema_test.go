@@ -0,0 +1,47 @@
+package models
+
+import "testing"
+
+func TestColumnSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  ColumnSchema
+		wantErr bool
+	}{
+		{"valid", ColumnSchema{"qty": {Type: ColumnInteger, Default: float64(1)}}, false},
+		{"unsupported type", ColumnSchema{"qty": {Type: "decimal"}}, true},
+		{"empty name", ColumnSchema{"": {Type: ColumnString}}, true},
+		{"default of another type", ColumnSchema{"qty": {Type: ColumnInteger, Default: "many"}}, true},
+		{"generator", ColumnSchema{"id": {Type: ColumnInteger, Generate: GenerateAutoIncrement}}, false},
+		{"generator of another type", ColumnSchema{"id": {Type: ColumnBoolean, Generate: GenerateUUID}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schema.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestColumnDefIsRequired(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		def  ColumnDef
+		want bool
+	}{
+		{"plain column", ColumnDef{Type: ColumnString}, true},
+		{"with default", ColumnDef{Type: ColumnString, Default: "x"}, false},
+		{"with generator", ColumnDef{Type: ColumnString, Generate: GenerateUUID}, false},
+		{"explicitly optional", ColumnDef{Type: ColumnString, Required: &no}, false},
+		{"explicitly required with default", ColumnDef{Type: ColumnString, Default: "x", Required: &yes}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.def.IsRequired(); got != tt.want {
+			t.Errorf("%s: IsRequired() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey is a stored worker write response keyed by Idempotency-Key.
+// ResponseStatus is nil while the first request is still in flight.
+type IdempotencyKey struct {
+	SheetID         uuid.UUID `db:"sheet_id"`
+	Key             string    `db:"idempotency_key"`
+	Fingerprint     string    `db:"fingerprint"`
+	ResponseStatus  *int      `db:"response_status"`
+	ResponseHeaders []byte    `db:"response_headers"`
+	ResponseBody    []byte    `db:"response_body"`
+	CreatedAt       time.Time `db:"created_at"`
+	ExpiresAt       time.Time `db:"expires_at"`
+}
@@ -0,0 +1,107 @@
+package models
+
+import "strings"
+
+// Origin and referer patterns restrict which sites may call a sheet through
+// the worker. An origin pattern is "*", an exact origin such as
+// "https://example.com" or "http://localhost:3000", or a subdomain wildcard
+// such as "https://*.example.com". A referer pattern is an origin pattern
+// optionally followed by a path prefix, e.g. "https://example.com/app".
+
+// IsValidOriginPattern reports whether pattern is a valid origin pattern
+func IsValidOriginPattern(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	scheme, host, ok := strings.Cut(pattern, "://")
+	if !ok || (scheme != "http" && scheme != "https") {
+		return false
+	}
+	host = strings.TrimPrefix(host, "*.")
+	return host != "" && !strings.ContainsAny(host, "/?#*@ ")
+}
+
+// IsValidRefererPattern reports whether pattern is a valid referer pattern
+func IsValidRefererPattern(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	origin, path := splitOrigin(pattern)
+	return IsValidOriginPattern(origin) && !strings.ContainsAny(path, "?#*")
+}
+
+// AllowsOrigin reports whether a browser with the given Origin header may call
+// the sheet. A sheet without allowed origins accepts any.
+func (s AllowedSheet) AllowsOrigin(origin string) bool {
+	if len(s.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, pattern := range s.AllowedOrigins {
+		if matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsReferer reports whether a request with the given Referer header may
+// call the sheet. Once referers are configured, an empty referer matches none.
+func (s AllowedSheet) AllowsReferer(referer string) bool {
+	if len(s.AllowedReferers) == 0 {
+		return true
+	}
+	if referer == "" {
+		return false
+	}
+	for _, pattern := range s.AllowedReferers {
+		if matchReferer(pattern, referer) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin compares an origin with a pattern, ignoring case and a trailing slash
+func matchOrigin(pattern, origin string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "/"))
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+	scheme, domain, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	host, ok := strings.CutPrefix(origin, scheme+"://")
+	return ok && strings.HasSuffix(host, "."+domain)
+}
+
+// matchReferer compares a referer URL with a pattern: the origins must match
+// and the referer's path must start with the pattern's path, if it has one
+func matchReferer(pattern, referer string) bool {
+	if pattern == "*" {
+		return true
+	}
+	patternOrigin, prefix := splitOrigin(pattern)
+	origin, path := splitOrigin(referer)
+	if !matchOrigin(patternOrigin, origin) {
+		return false
+	}
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// splitOrigin splits a URL into its "scheme://host[:port]" origin and the rest
+func splitOrigin(url string) (string, string) {
+	i := strings.Index(url, "://")
+	if i < 0 {
+		return url, ""
+	}
+	if j := strings.IndexAny(url[i+3:], "/?#"); j >= 0 {
+		return url[:i+3+j], url[i+3+j:]
+	}
+	return url, ""
+}
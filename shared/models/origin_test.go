@@ -0,0 +1,104 @@
+package models
+
+import "testing"
+
+func TestIsValidOriginPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"*", true},
+		{"https://example.com", true},
+		{"http://localhost:3000", true},
+		{"https://*.example.com", true},
+		{"example.com", false},
+		{"ftp://example.com", false},
+		{"https://example.com/app", false},
+		{"https://a.*.example.com", false},
+		{"https://", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidOriginPattern(tt.pattern); got != tt.want {
+			t.Errorf("IsValidOriginPattern(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidRefererPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"*", true},
+		{"https://example.com", true},
+		{"https://example.com/app", true},
+		{"https://*.example.com/app/", true},
+		{"https://example.com/app?x=1", false},
+		{"https://example.com/*", false},
+		{"/app", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidRefererPattern(tt.pattern); got != tt.want {
+			t.Errorf("IsValidRefererPattern(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestAllowsOrigin(t *testing.T) {
+	sheet := AllowedSheet{AllowedOrigins: []string{"https://example.com/", "https://*.example.org"}}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://example.com", true},
+		{"HTTPS://Example.com", true},
+		{"http://example.com", false},
+		{"https://evil.com", false},
+		{"https://app.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://evilexample.org", false},
+		{"http://app.example.org", false},
+	}
+
+	for _, tt := range tests {
+		if got := sheet.AllowsOrigin(tt.origin); got != tt.want {
+			t.Errorf("AllowsOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	if !(AllowedSheet{}).AllowsOrigin("https://anything.test") {
+		t.Error("a sheet without allowed origins refused an origin")
+	}
+}
+
+func TestAllowsReferer(t *testing.T) {
+	sheet := AllowedSheet{AllowedReferers: []string{"https://example.com/app/", "https://*.example.org"}}
+
+	tests := []struct {
+		referer string
+		want    bool
+	}{
+		{"https://example.com/app", true},
+		{"https://example.com/app/page?x=1", true},
+		{"https://example.com/app#top", true},
+		{"https://example.com/apple", false},
+		{"https://example.com/", false},
+		{"https://shop.example.org/any/path", true},
+		{"https://evil.com/app", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := sheet.AllowsReferer(tt.referer); got != tt.want {
+			t.Errorf("AllowsReferer(%q) = %v, want %v", tt.referer, got, tt.want)
+		}
+	}
+
+	if !(AllowedSheet{}).AllowsReferer("") {
+		t.Error("a sheet without allowed referers refused a request without a referer")
+	}
+}
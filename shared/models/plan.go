@@ -37,8 +37,16 @@ type PlanLimits struct {
 
 	// Features
 	CacheMinTTL     int  // Minimum cache TTL in seconds
+	CacheBypass     bool // Allow nocache=1 to skip the response cache
 	CustomDomain    bool // Allow custom domain
 	PrioritySupport bool // Priority support access
+
+	// Conditional requests
+	NotModifiedWeight int // Percent of a GET quota unit charged for a 304 Not Modified (0 = free)
+
+	// Bulk writes
+	BulkRowsPerUnit int // Rows of a bulk insert charged as one update quota unit
+	MaxBulkRows     int // Maximum rows in a single bulk insert
 }
 
 // GetPlanLimits returns the limits for a given subscription plan
@@ -54,8 +62,12 @@ func GetPlanLimits(plan SubscriptionPlan) PlanLimits {
 			MonthlyPrice:       0,
 			AnnualPrice:        0,
 			CacheMinTTL:        60, // Force 60s cache on free tier
+			CacheBypass:        false,
 			CustomDomain:       false,
 			PrioritySupport:    false,
+			NotModifiedWeight:  50,
+			BulkRowsPerUnit:    1,
+			MaxBulkRows:        50,
 		}
 	case PlanStarter:
 		return PlanLimits{
@@ -67,8 +79,12 @@ func GetPlanLimits(plan SubscriptionPlan) PlanLimits {
 			MonthlyPrice:       499,  // $4.99
 			AnnualPrice:        4799, // $47.99 (~$3.99/mo)
 			CacheMinTTL:        30,
+			CacheBypass:        false,
 			CustomDomain:       false,
 			PrioritySupport:    false,
+			NotModifiedWeight:  25,
+			BulkRowsPerUnit:    5,
+			MaxBulkRows:        200,
 		}
 	case PlanPro:
 		return PlanLimits{
@@ -80,8 +96,12 @@ func GetPlanLimits(plan SubscriptionPlan) PlanLimits {
 			MonthlyPrice:       1999,  // $19.99
 			AnnualPrice:        19199, // $191.99 (~$15.99/mo)
 			CacheMinTTL:        10,
+			CacheBypass:        true,
 			CustomDomain:       true,
 			PrioritySupport:    true,
+			NotModifiedWeight:  10,
+			BulkRowsPerUnit:    20,
+			MaxBulkRows:        1000,
 		}
 	case PlanEnterprise:
 		return PlanLimits{
@@ -93,8 +113,12 @@ func GetPlanLimits(plan SubscriptionPlan) PlanLimits {
 			MonthlyPrice:       9900, // $99 starting
 			AnnualPrice:        0,    // Custom negotiation
 			CacheMinTTL:        0,    // No minimum
+			CacheBypass:        true,
 			CustomDomain:       true,
 			PrioritySupport:    true,
+			NotModifiedWeight:  0,
+			BulkRowsPerUnit:    100,
+			MaxBulkRows:        5000,
 		}
 	default:
 		return GetPlanLimits(PlanFree)
@@ -113,6 +137,27 @@ func (p PlanLimits) GetEffectiveRateLimit(method string) int {
 	}
 }
 
+// GetEffectiveCacheTTL clamps a requested response cache TTL (in seconds) to the plan minimum
+func (p PlanLimits) GetEffectiveCacheTTL(requested int) int {
+	if requested < p.CacheMinTTL {
+		return p.CacheMinTTL
+	}
+	return requested
+}
+
+// GetBulkWriteUnits returns the update quota units charged for inserting rows in one request
+func (p PlanLimits) GetBulkWriteUnits(rows int) int {
+	perUnit := p.BulkRowsPerUnit
+	if perUnit <= 0 {
+		perUnit = 1
+	}
+	units := (rows + perUnit - 1) / perUnit
+	if units < 1 {
+		return 1
+	}
+	return units
+}
+
 // IsWriteMethod returns true if the method is a write operation
 func IsWriteMethod(method string) bool {
 	switch method {
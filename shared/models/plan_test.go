@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestGetBulkWriteUnits(t *testing.T) {
+	tests := []struct {
+		perUnit int
+		rows    int
+		want    int
+	}{
+		{0, 1, 1},
+		{0, 7, 7},
+		{50, 1, 1},
+		{50, 50, 1},
+		{50, 51, 2},
+		{50, 0, 1},
+	}
+
+	for _, tt := range tests {
+		limits := PlanLimits{BulkRowsPerUnit: tt.perUnit}
+		if got := limits.GetBulkWriteUnits(tt.rows); got != tt.want {
+			t.Errorf("GetBulkWriteUnits(%d) with %d rows per unit = %d, want %d", tt.rows, tt.perUnit, got, tt.want)
+		}
+	}
+}
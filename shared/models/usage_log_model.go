@@ -8,13 +8,14 @@ import (
 
 // ApiUsageDaily represents daily API usage statistics
 type ApiUsageDaily struct {
-	ID           uuid.UUID `db:"id"`
-	ApiKey       string    `db:"api_key"`
-	UserID       uuid.UUID `db:"user_id"`
-	SheetID      uuid.UUID `db:"sheet_id"`
-	RequestDate  time.Time `db:"request_date"`
-	Method       string    `db:"method"`
-	RequestCount int       `db:"request_count"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	ID           uuid.UUID  `db:"id"`
+	ApiKey       string     `db:"api_key"`
+	ApiKeyID     *uuid.UUID `db:"api_key_id"` // named API key, nil for the sheet credentials
+	UserID       uuid.UUID  `db:"user_id"`
+	SheetID      uuid.UUID  `db:"sheet_id"`
+	RequestDate  time.Time  `db:"request_date"`
+	Method       string     `db:"method"`
+	RequestCount int        `db:"request_count"`
+	CreatedAt    time.Time  `db:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at"`
 }
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
 	"errors"
 	"time"
@@ -30,7 +31,14 @@ type AllowedSheetRepo interface {
 	Unpublish(ctx context.Context, sheetID uuid.UUID) error
 	UpdateWriteSettings(ctx context.Context, sheetID uuid.UUID, allowWrite bool) error
 	UpdateAllowedMethods(ctx context.Context, sheetID uuid.UUID, allowedMethods []string) error
+	UpdateValueInputMode(ctx context.Context, sheetID uuid.UUID, mode *string) error
 	UpdateAuth(ctx context.Context, sheetID uuid.UUID, authType string, bearerToken, basicUsername, basicPasswordHash *string) error
+	RotateBearerToken(ctx context.Context, sheetID uuid.UUID, token string, graceUntil *time.Time) error
+	EndBearerTokenGrace(ctx context.Context, sheetID uuid.UUID) error
+	UpdateColumnSchema(ctx context.Context, sheetID uuid.UUID, schema models.ColumnSchema, strictFields bool) error
+	UpdatePrimaryKeyColumn(ctx context.Context, sheetID uuid.UUID, column *string) error
+	UpdateSearchColumns(ctx context.Context, sheetID uuid.UUID, columns []string) error
+	UpdateAllowedOrigins(ctx context.Context, sheetID uuid.UUID, origins, referers []string) error
 }
 
 type allowedSheetRepo struct {
@@ -148,6 +156,17 @@ func (r *allowedSheetRepo) UpdateWriteSettings(ctx context.Context, sheetID uuid
 	return err
 }
 
+// UpdateValueInputMode sets how worker writes treat client values (nil restores the default)
+func (r *allowedSheetRepo) UpdateValueInputMode(ctx context.Context, sheetID uuid.UUID, mode *string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE allowed_sheets 
+		SET value_input_mode = $1,
+		    updated_at = NOW()
+		WHERE id = $2
+	`, mode, sheetID)
+	return err
+}
+
 func (r *allowedSheetRepo) UpdateAllowedMethods(ctx context.Context, sheetID uuid.UUID, allowedMethods []string) error {
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE allowed_sheets 
@@ -158,15 +177,54 @@ func (r *allowedSheetRepo) UpdateAllowedMethods(ctx context.Context, sheetID uui
 	return err
 }
 
-// FindByBearerToken finds a sheet by bearer token (auth_type = 'bearer')
-// Bearer tokens are compared directly (stored as-is in the database).
+// FindByBearerToken finds a sheet by bearer token (auth_type = 'bearer').
+// Tokens are looked up by their SHA-256 digest; the token replaced by the last
+// rotation matches too until its grace window ends (see
+// AllowedSheet.AcceptsBearerToken). A token stored in plaintext before
+// hashing is matched once and then replaced by its digest.
 func (r *allowedSheetRepo) FindByBearerToken(ctx context.Context, token string) (models.AllowedSheet, error) {
+	digest := HashToken(token)
+
 	var sheet models.AllowedSheet
 	err := r.db.GetContext(ctx, &sheet, `
+		SELECT * FROM allowed_sheets 
+		WHERE auth_type = 'bearer' AND is_public = true
+		  AND (auth_bearer_token_digest = $1
+		       OR (auth_previous_bearer_token_digest = $1 AND auth_previous_bearer_token_expires_at > NOW()))
+		LIMIT 1
+	`, digest)
+	if err == nil {
+		if !sheet.AcceptsBearerToken(digest, time.Now()) {
+			return models.AllowedSheet{}, ErrUnauthorized
+		}
+		return sheet, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return sheet, err
+	}
+
+	err = r.db.GetContext(ctx, &sheet, `
 		SELECT * FROM allowed_sheets 
 		WHERE auth_type = 'bearer' AND auth_bearer_token = $1 AND is_public = true
 	`, token)
-	return sheet, err
+	if err != nil {
+		return sheet, err
+	}
+
+	prefix := TokenPrefix(token)
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE allowed_sheets 
+		SET auth_bearer_token = NULL,
+		    auth_bearer_token_digest = $1,
+		    auth_bearer_token_prefix = $2
+		WHERE id = $3 AND auth_bearer_token = $4
+	`, digest, prefix, sheet.ID, token); err != nil {
+		return sheet, err
+	}
+	sheet.AuthBearerToken = nil
+	sheet.AuthBearerTokenDigest = &digest
+	sheet.AuthBearerTokenPrefix = &prefix
+	return sheet, nil
 }
 
 // FindByBasicCredentials finds a sheet by basic auth credentials (auth_type = 'basic')
@@ -191,17 +249,139 @@ func (r *allowedSheetRepo) FindByBasicCredentials(ctx context.Context, username,
 	return sheet, nil
 }
 
-// UpdateAuth updates the authentication type and credentials for a sheet
+// UpdateAuth updates the authentication type and credentials for a sheet.
+// The bearer token is stored as its digest and visible prefix; a token still
+// in its rotation grace window stops working.
 func (r *allowedSheetRepo) UpdateAuth(ctx context.Context, sheetID uuid.UUID, authType string, bearerToken, basicUsername, basicPasswordHash *string) error {
+	var digest, prefix *string
+	if bearerToken != nil {
+		d, p := HashToken(*bearerToken), TokenPrefix(*bearerToken)
+		digest, prefix = &d, &p
+	}
+
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE allowed_sheets 
 		SET auth_type = $1,
-		    auth_bearer_token = $2,
-		    auth_basic_username = $3,
-		    auth_basic_password_hash = $4,
+		    auth_bearer_token = NULL,
+		    auth_bearer_token_digest = $2,
+		    auth_bearer_token_prefix = $3,
+		    auth_previous_bearer_token_digest = NULL,
+		    auth_previous_bearer_token_prefix = NULL,
+		    auth_previous_bearer_token_expires_at = NULL,
+		    auth_basic_username = $4,
+		    auth_basic_password_hash = $5,
+		    updated_at = NOW()
+		WHERE id = $6
+	`, authType, digest, prefix, basicUsername, basicPasswordHash, sheetID)
+	return err
+}
+
+// RotateBearerToken replaces the sheet's bearer token. With graceUntil the
+// replaced token keeps authenticating until then; without it the replaced
+// token stops working at once.
+func (r *allowedSheetRepo) RotateBearerToken(ctx context.Context, sheetID uuid.UUID, token string, graceUntil *time.Time) error {
+	sheet, err := r.FindByID(ctx, sheetID)
+	if err != nil {
+		return err
+	}
+
+	var prevDigest, prevPrefix *string
+	if graceUntil != nil {
+		switch {
+		case sheet.AuthBearerTokenDigest != nil:
+			prevDigest, prevPrefix = sheet.AuthBearerTokenDigest, sheet.AuthBearerTokenPrefix
+		case sheet.AuthBearerToken != nil:
+			d, p := HashToken(*sheet.AuthBearerToken), TokenPrefix(*sheet.AuthBearerToken)
+			prevDigest, prevPrefix = &d, &p
+		}
+	}
+	if prevDigest == nil {
+		graceUntil = nil
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE allowed_sheets 
+		SET auth_type = 'bearer',
+		    auth_bearer_token = NULL,
+		    auth_bearer_token_digest = $1,
+		    auth_bearer_token_prefix = $2,
+		    auth_previous_bearer_token_digest = $3,
+		    auth_previous_bearer_token_prefix = $4,
+		    auth_previous_bearer_token_expires_at = $5,
+		    updated_at = NOW()
+		WHERE id = $6
+	`, HashToken(token), TokenPrefix(token), prevDigest, prevPrefix, graceUntil, sheetID)
+	return err
+}
+
+// EndBearerTokenGrace stops the token replaced by the last rotation from
+// authenticating before its grace window ends
+func (r *allowedSheetRepo) EndBearerTokenGrace(ctx context.Context, sheetID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE allowed_sheets 
+		SET auth_previous_bearer_token_digest = NULL,
+		    auth_previous_bearer_token_prefix = NULL,
+		    auth_previous_bearer_token_expires_at = NULL,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, sheetID)
+	return err
+}
+
+// UpdateColumnSchema replaces the column schema for a sheet (nil clears it)
+// and whether writes with unknown fields are rejected
+func (r *allowedSheetRepo) UpdateColumnSchema(ctx context.Context, sheetID uuid.UUID, schema models.ColumnSchema, strictFields bool) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE allowed_sheets 
+		SET column_schema = $1,
+		    strict_fields = $2,
+		    updated_at = NOW()
+		WHERE id = $3
+	`, schema, strictFields, sheetID)
+	return err
+}
+
+// UpdatePrimaryKeyColumn sets the column that uniquely identifies rows (nil clears it)
+func (r *allowedSheetRepo) UpdatePrimaryKeyColumn(ctx context.Context, sheetID uuid.UUID, column *string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE allowed_sheets 
+		SET primary_key_column = NULLIF($1, ''),
+		    updated_at = NOW()
+		WHERE id = $2
+	`, column, sheetID)
+	return err
+}
+
+// UpdateSearchColumns sets the columns searched by q= (empty searches all columns)
+func (r *allowedSheetRepo) UpdateSearchColumns(ctx context.Context, sheetID uuid.UUID, columns []string) error {
+	if columns == nil {
+		columns = []string{}
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE allowed_sheets 
+		SET search_columns = $1,
+		    updated_at = NOW()
+		WHERE id = $2
+	`, pq.Array(columns), sheetID)
+	return err
+}
+
+// UpdateAllowedOrigins sets the origins and referers the worker accepts
+// (empty accepts any)
+func (r *allowedSheetRepo) UpdateAllowedOrigins(ctx context.Context, sheetID uuid.UUID, origins, referers []string) error {
+	if origins == nil {
+		origins = []string{}
+	}
+	if referers == nil {
+		referers = []string{}
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE allowed_sheets
+		SET allowed_origins = $1,
+		    allowed_referers = $2,
 		    updated_at = NOW()
-		WHERE id = $5
-	`, authType, bearerToken, basicUsername, basicPasswordHash, sheetID)
+		WHERE id = $3
+	`, pq.Array(origins), pq.Array(referers), sheetID)
 	return err
 }
 
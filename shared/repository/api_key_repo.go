@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gsheetbase/shared/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// APIKeyRepo manages the named API keys of sheets
+type APIKeyRepo interface {
+	Create(ctx context.Context, sheetID uuid.UUID, label string, scopes []string, expiresAt *time.Time) (models.APIKey, string, error)
+	FindBySheetID(ctx context.Context, sheetID uuid.UUID) ([]models.APIKey, error)
+	FindByID(ctx context.Context, sheetID, id uuid.UUID) (models.APIKey, error)
+	FindByKey(ctx context.Context, key string) (models.APIKey, error)
+	Update(ctx context.Context, sheetID, id uuid.UUID, label string, scopes []string, expiresAt *time.Time) (models.APIKey, error)
+	Delete(ctx context.Context, sheetID, id uuid.UUID) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID) error
+}
+
+type apiKeyRepo struct {
+	db *sqlx.DB
+}
+
+// NewAPIKeyRepo creates a new API key repository
+func NewAPIKeyRepo(db *sqlx.DB) APIKeyRepo {
+	return &apiKeyRepo{db: db}
+}
+
+// Create generates a new key for the sheet and returns it with the stored
+// record, which only holds its digest
+func (r *apiKeyRepo) Create(ctx context.Context, sheetID uuid.UUID, label string, scopes []string, expiresAt *time.Time) (models.APIKey, string, error) {
+	var apiKey models.APIKey
+	key, err := GenerateBearerToken()
+	if err != nil {
+		return apiKey, "", err
+	}
+
+	err = r.db.GetContext(ctx, &apiKey, `
+		INSERT INTO api_keys (sheet_id, label, key_digest, key_prefix, scopes, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING *
+	`, sheetID, label, HashToken(key), TokenPrefix(key), pq.StringArray(scopes), expiresAt)
+	return apiKey, key, err
+}
+
+func (r *apiKeyRepo) FindBySheetID(ctx context.Context, sheetID uuid.UUID) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := r.db.SelectContext(ctx, &keys, `
+		SELECT * FROM api_keys WHERE sheet_id = $1 ORDER BY created_at DESC
+	`, sheetID)
+	return keys, err
+}
+
+func (r *apiKeyRepo) FindByID(ctx context.Context, sheetID, id uuid.UUID) (models.APIKey, error) {
+	var apiKey models.APIKey
+	err := r.db.GetContext(ctx, &apiKey, `
+		SELECT * FROM api_keys WHERE sheet_id = $1 AND id = $2
+	`, sheetID, id)
+	return apiKey, err
+}
+
+// FindByKey finds a key by its digest; expiry is left to the caller
+func (r *apiKeyRepo) FindByKey(ctx context.Context, key string) (models.APIKey, error) {
+	var apiKey models.APIKey
+	err := r.db.GetContext(ctx, &apiKey, `
+		SELECT * FROM api_keys WHERE key_digest = $1
+	`, HashToken(key))
+	return apiKey, err
+}
+
+// Update replaces the label, scopes and expiry of a key
+func (r *apiKeyRepo) Update(ctx context.Context, sheetID, id uuid.UUID, label string, scopes []string, expiresAt *time.Time) (models.APIKey, error) {
+	var apiKey models.APIKey
+	err := r.db.GetContext(ctx, &apiKey, `
+		UPDATE api_keys
+		SET label = $3,
+		    scopes = $4,
+		    expires_at = $5,
+		    updated_at = NOW()
+		WHERE sheet_id = $1 AND id = $2
+		RETURNING *
+	`, sheetID, id, label, pq.StringArray(scopes), expiresAt)
+	return apiKey, err
+}
+
+// Delete revokes a key
+func (r *apiKeyRepo) Delete(ctx context.Context, sheetID, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM api_keys WHERE sheet_id = $1 AND id = $2
+	`, sheetID, id)
+	return err
+}
+
+// TouchLastUsed records that a key was used. The timestamp is only moved once
+// a minute so busy keys don't write on every request.
+func (r *apiKeyRepo) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, id)
+	return err
+}
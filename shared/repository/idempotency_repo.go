@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gsheetbase/shared/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// IdempotencyRepo stores worker write responses by Idempotency-Key
+type IdempotencyRepo interface {
+	Reserve(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, expiresAt time.Time) (models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, sheetID uuid.UUID, key string, status int, headers, body []byte, expiresAt time.Time) error
+	Release(ctx context.Context, sheetID uuid.UUID, key string) error
+}
+
+type idempotencyRepo struct {
+	db *sqlx.DB
+}
+
+// NewIdempotencyRepo creates a new idempotency key repository
+func NewIdempotencyRepo(db *sqlx.DB) IdempotencyRepo {
+	return &idempotencyRepo{db: db}
+}
+
+// Reserve claims a key for an in-flight request. It returns true when the key
+// was claimed, otherwise the existing entry. Expired entries of the sheet are
+// removed first so a key can be reused once its window has passed.
+func (r *idempotencyRepo) Reserve(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, expiresAt time.Time) (models.IdempotencyKey, bool, error) {
+	var entry models.IdempotencyKey
+
+	if _, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE sheet_id = $1 AND expires_at < NOW()
+	`, sheetID); err != nil {
+		return entry, false, err
+	}
+
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (sheet_id, idempotency_key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, NOW(), $4)
+		ON CONFLICT (sheet_id, idempotency_key) DO NOTHING
+	`, sheetID, key, fingerprint, expiresAt)
+	if err != nil {
+		return entry, false, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 1 {
+		return entry, true, nil
+	}
+
+	err = r.db.GetContext(ctx, &entry, `
+		SELECT sheet_id, idempotency_key, fingerprint, response_status, response_headers, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE sheet_id = $1 AND idempotency_key = $2
+	`, sheetID, key)
+	return entry, false, err
+}
+
+// Complete stores the response of a reserved key until expiresAt
+func (r *idempotencyRepo) Complete(ctx context.Context, sheetID uuid.UUID, key string, status int, headers, body []byte, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET response_status = $1,
+		    response_headers = $2,
+		    response_body = $3,
+		    expires_at = $4
+		WHERE sheet_id = $5 AND idempotency_key = $6
+	`, status, headers, body, expiresAt, sheetID, key)
+	return err
+}
+
+// Release drops a reserved key so the request can be retried
+func (r *idempotencyRepo) Release(ctx context.Context, sheetID uuid.UUID, key string) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE sheet_id = $1 AND idempotency_key = $2
+	`, sheetID, key)
+	return err
+}
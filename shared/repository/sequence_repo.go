@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// SequenceRepo reserves auto_increment values for generated sheet columns
+type SequenceRepo interface {
+	Reserve(ctx context.Context, sheetID uuid.UUID, collection, column string, floor int64, n int) (int64, error)
+}
+
+type sequenceRepo struct {
+	db *sqlx.DB
+}
+
+// NewSequenceRepo creates a new column sequence repository
+func NewSequenceRepo(db *sqlx.DB) SequenceRepo {
+	return &sequenceRepo{db: db}
+}
+
+// Reserve claims n consecutive values of a column's sequence and returns the
+// first. Values start at floor or after the last one reserved, whichever is
+// higher, so rows added to the sheet by hand are skipped. Concurrent callers
+// are serialized on the sequence row and never get the same value.
+func (r *sequenceRepo) Reserve(ctx context.Context, sheetID uuid.UUID, collection, column string, floor int64, n int) (int64, error) {
+	var last int64
+	err := r.db.GetContext(ctx, &last, `
+		INSERT INTO column_sequences (sheet_id, collection, column_name, last_value, updated_at)
+		VALUES ($1, $2, $3, $4::BIGINT - 1 + $5, NOW())
+		ON CONFLICT (sheet_id, collection, column_name) DO UPDATE
+		SET last_value = GREATEST(column_sequences.last_value, $4::BIGINT - 1) + $5,
+		    updated_at = NOW()
+		RETURNING last_value
+	`, sheetID, collection, column, floor, n)
+	if err != nil {
+		return 0, err
+	}
+	return last - int64(n) + 1, nil
+}
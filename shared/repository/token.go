@@ -2,18 +2,38 @@ package repository
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 )
 
-// GenerateBearerToken returns a cryptographically secure bearer token
-// prefixed with "gsheet_". It uses URL-safe base64 encoding and does
-// not assume a fixed encoded length to avoid slicing panics.
-func GenerateBearerToken() string {
+// GenerateBearerToken returns a cryptographically secure token prefixed with
+// "gskey_", used for bearer tokens and named API keys. It uses URL-safe
+// base64 encoding of 32 random bytes.
+func GenerateBearerToken() (string, error) {
 	b := make([]byte, 32)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "gskey_fallback_token"
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
 	}
-	s := base64.URLEncoding.EncodeToString(b)
-	return "gskey_" + s
+	return "gskey_" + base64.URLEncoding.EncodeToString(b), nil
+}
+
+// tokenPrefixLength is how much of a token stays visible to identify it,
+// e.g. "gskey_ab12"
+const tokenPrefixLength = 10
+
+// HashToken returns the hex SHA-256 digest under which a token is stored.
+// Tokens carry 256 random bits, so an unsalted digest cannot be brute-forced.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenPrefix returns the visible start of a token
+func TokenPrefix(token string) string {
+	if len(token) <= tokenPrefixLength {
+		return token
+	}
+	return token[:tokenPrefixLength]
 }
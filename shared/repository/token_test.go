@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestGenerateBearerToken(t *testing.T) {
+	a, err := GenerateBearerToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateBearerToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(a, "gskey_") || len(a) != len("gskey_")+44 {
+		t.Errorf("token %q does not look like gskey_ + 32 base64 bytes", a)
+	}
+	if a == b {
+		t.Error("two generated tokens are equal")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	if got, want := HashToken("abc"), "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; got != want {
+		t.Errorf("HashToken(abc) = %s, want %s", got, want)
+	}
+}
+
+func TestTokenPrefix(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"gskey_ab12cd34ef", "gskey_ab12"},
+		{"gskey_ab12", "gskey_ab12"},
+		{"short", "short"},
+	}
+
+	for _, tt := range tests {
+		if got := TokenPrefix(tt.token); got != tt.want {
+			t.Errorf("TokenPrefix(%q) = %q, want %q", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestTokenLookupsUseDigest(t *testing.T) {
+	const token = "gskey_secret"
+
+	tests := []struct {
+		name   string
+		lookup func(db *sqlx.DB) error
+	}{
+		{"api key", func(db *sqlx.DB) error {
+			_, err := NewAPIKeyRepo(db).FindByKey(context.Background(), token)
+			return err
+		}},
+		{"bearer token", func(db *sqlx.DB) error {
+			_, err := NewAllowedSheetRepo(db).FindByBearerToken(context.Background(), token)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingConnector{}
+			db := sqlx.NewDb(sql.OpenDB(rec), "postgres")
+			defer db.Close()
+
+			if err := tt.lookup(db); err == nil {
+				t.Fatal("lookup found a row in an empty database")
+			}
+			if len(rec.queries) == 0 {
+				t.Fatal("no query was run")
+			}
+			first := rec.queries[0]
+			if len(first) != 1 || first[0] != HashToken(token) {
+				t.Errorf("first lookup args = %v, want the token digest", first)
+			}
+		})
+	}
+}
+
+// recordingConnector is a database/sql driver that answers every query with
+// no rows and records the arguments of each query
+type recordingConnector struct {
+	queries [][]driver.Value
+}
+
+func (r *recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return recordingConn{r}, nil
+}
+func (r *recordingConnector) Driver() driver.Driver { return nil }
+
+type recordingConn struct{ rec *recordingConnector }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) { return recordingStmt(c), nil }
+func (c recordingConn) Close() error                              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type recordingStmt struct{ rec *recordingConnector }
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.rec.queries = append(s.rec.queries, args)
+	return driver.RowsAffected(0), nil
+}
+func (s recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.rec.queries = append(s.rec.queries, args)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return nil }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
@@ -12,7 +12,7 @@ import (
 
 // UsageRepo defines the interface for usage tracking operations
 type UsageRepo interface {
-	IncrementDailyUsage(ctx context.Context, apiKey string, userID, sheetID uuid.UUID, date time.Time, method string) error
+	IncrementDailyUsage(ctx context.Context, apiKey string, apiKeyID *uuid.UUID, userID, sheetID uuid.UUID, date time.Time, method string, count int) error
 	GetDailyUsageBySheet(ctx context.Context, sheetID uuid.UUID, startDate, endDate time.Time) ([]models.ApiUsageDaily, error)
 	GetDailyUsageByUser(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]models.ApiUsageDaily, error)
 	GetDailyUsageByAPIKey(ctx context.Context, apiKey string, startDate, endDate time.Time) ([]models.ApiUsageDaily, error)
@@ -31,27 +31,29 @@ func NewUsageRepo(db *sqlx.DB) UsageRepo {
 	return &usageRepo{db: db}
 }
 
-// IncrementDailyUsage atomically increments the usage counter
-func (r *usageRepo) IncrementDailyUsage(ctx context.Context, apiKey string, userID, sheetID uuid.UUID, date time.Time, method string) error {
+// IncrementDailyUsage atomically adds count to the usage counter. apiKeyID
+// names the API key that made the requests, nil for the sheet credentials;
+// each key gets its own row under the sheet's apiKey.
+func (r *usageRepo) IncrementDailyUsage(ctx context.Context, apiKey string, apiKeyID *uuid.UUID, userID, sheetID uuid.UUID, date time.Time, method string, count int) error {
 	dateOnly := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
 
 	query := `
-		INSERT INTO api_usage_daily (api_key, user_id, sheet_id, request_date, method, request_count, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, 1, NOW(), NOW())
-		ON CONFLICT (api_key, request_date, method)
+		INSERT INTO api_usage_daily (api_key, api_key_id, user_id, sheet_id, request_date, method, request_count, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		ON CONFLICT (api_key, (COALESCE(api_key_id, '00000000-0000-0000-0000-000000000000')), request_date, method)
 		DO UPDATE SET 
-			request_count = api_usage_daily.request_count + 1,
+			request_count = api_usage_daily.request_count + EXCLUDED.request_count,
 			updated_at = NOW()
 	`
 
-	_, err := r.db.ExecContext(ctx, query, apiKey, userID, sheetID, dateOnly, method)
+	_, err := r.db.ExecContext(ctx, query, apiKey, apiKeyID, userID, sheetID, dateOnly, method, count)
 	return err
 }
 
 // GetDailyUsageBySheet retrieves usage stats for a specific sheet
 func (r *usageRepo) GetDailyUsageBySheet(ctx context.Context, sheetID uuid.UUID, startDate, endDate time.Time) ([]models.ApiUsageDaily, error) {
 	query := `
-		SELECT id, api_key, user_id, sheet_id, request_date, method, request_count, created_at, updated_at
+		SELECT id, api_key, api_key_id, user_id, sheet_id, request_date, method, request_count, created_at, updated_at
 		FROM api_usage_daily
 		WHERE sheet_id = $1 AND request_date >= $2 AND request_date <= $3
 		ORDER BY request_date ASC, method
@@ -66,7 +68,7 @@ func (r *usageRepo) GetDailyUsageBySheet(ctx context.Context, sheetID uuid.UUID,
 	var results []models.ApiUsageDaily
 	for rows.Next() {
 		var usage models.ApiUsageDaily
-		err := rows.Scan(&usage.ID, &usage.ApiKey, &usage.UserID, &usage.SheetID, &usage.RequestDate, &usage.Method, &usage.RequestCount, &usage.CreatedAt, &usage.UpdatedAt)
+		err := rows.Scan(&usage.ID, &usage.ApiKey, &usage.ApiKeyID, &usage.UserID, &usage.SheetID, &usage.RequestDate, &usage.Method, &usage.RequestCount, &usage.CreatedAt, &usage.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -79,7 +81,7 @@ func (r *usageRepo) GetDailyUsageBySheet(ctx context.Context, sheetID uuid.UUID,
 // GetDailyUsageByUser retrieves usage stats for all sheets owned by a user
 func (r *usageRepo) GetDailyUsageByUser(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]models.ApiUsageDaily, error) {
 	query := `
-		SELECT id, api_key, user_id, sheet_id, request_date, method, request_count, created_at, updated_at
+		SELECT id, api_key, api_key_id, user_id, sheet_id, request_date, method, request_count, created_at, updated_at
 		FROM api_usage_daily
 		WHERE user_id = $1 AND request_date >= $2 AND request_date <= $3
 		ORDER BY request_date DESC, sheet_id, method
@@ -94,7 +96,7 @@ func (r *usageRepo) GetDailyUsageByUser(ctx context.Context, userID uuid.UUID, s
 	var results []models.ApiUsageDaily
 	for rows.Next() {
 		var usage models.ApiUsageDaily
-		err := rows.Scan(&usage.ID, &usage.ApiKey, &usage.UserID, &usage.SheetID, &usage.RequestDate, &usage.Method, &usage.RequestCount, &usage.CreatedAt, &usage.UpdatedAt)
+		err := rows.Scan(&usage.ID, &usage.ApiKey, &usage.ApiKeyID, &usage.UserID, &usage.SheetID, &usage.RequestDate, &usage.Method, &usage.RequestCount, &usage.CreatedAt, &usage.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -107,7 +109,7 @@ func (r *usageRepo) GetDailyUsageByUser(ctx context.Context, userID uuid.UUID, s
 // GetDailyUsageByAPIKey retrieves usage stats for a specific API key
 func (r *usageRepo) GetDailyUsageByAPIKey(ctx context.Context, apiKey string, startDate, endDate time.Time) ([]models.ApiUsageDaily, error) {
 	query := `
-		SELECT id, api_key, user_id, sheet_id, request_date, method, request_count, created_at, updated_at
+		SELECT id, api_key, api_key_id, user_id, sheet_id, request_date, method, request_count, created_at, updated_at
 		FROM api_usage_daily
 		WHERE api_key = $1 AND request_date >= $2 AND request_date <= $3
 		ORDER BY request_date DESC, method
@@ -122,7 +124,7 @@ func (r *usageRepo) GetDailyUsageByAPIKey(ctx context.Context, apiKey string, st
 	var results []models.ApiUsageDaily
 	for rows.Next() {
 		var usage models.ApiUsageDaily
-		err := rows.Scan(&usage.ID, &usage.ApiKey, &usage.UserID, &usage.SheetID, &usage.RequestDate, &usage.Method, &usage.RequestCount, &usage.CreatedAt, &usage.UpdatedAt)
+		err := rows.Scan(&usage.ID, &usage.ApiKey, &usage.ApiKeyID, &usage.UserID, &usage.SheetID, &usage.RequestDate, &usage.Method, &usage.RequestCount, &usage.CreatedAt, &usage.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -32,6 +32,7 @@ func main() {
 	userRepo := repository.NewUserRepo(db)
 	allowedSheetRepo := repository.NewAllowedSheetRepo(db)
 	usageRepo := repository.NewUsageRepo(db)
+	apiKeyRepo := repository.NewAPIKeyRepo(db)
 
 	// Services
 	authService := services.NewAuthService(cfg, userRepo)
@@ -80,22 +81,33 @@ func main() {
 	api.POST("/auth/refresh-session", refreshAuthHandler.RefreshSession)
 
 	// Sheet registration (must register sheets before accessing them)
-	allowedSheetHandler := handlers.NewAllowedSheetHandler(allowedSheetRepo)
+	allowedSheetHandler := handlers.NewAllowedSheetHandler(allowedSheetRepo, apiKeyRepo)
 	api.POST("/sheets/register", middleware.Authenticate(cfg, authService), allowedSheetHandler.Register)
 	api.GET("/sheets/registered", middleware.Authenticate(cfg, authService), allowedSheetHandler.List)
 	api.DELETE("/sheets/registered/:sheet_id", middleware.Authenticate(cfg, authService), allowedSheetHandler.Delete)
 	api.POST("/sheets/:id/publish", middleware.Authenticate(cfg, authService), allowedSheetHandler.Publish)
 	api.DELETE("/sheets/:id/unpublish", middleware.Authenticate(cfg, authService), allowedSheetHandler.Unpublish)
 	api.PATCH("/sheets/:id/write-settings", middleware.Authenticate(cfg, authService), allowedSheetHandler.UpdateWriteSettings)
+	api.PUT("/sheets/:id/schema", middleware.Authenticate(cfg, authService), allowedSheetHandler.UpdateColumnSchema)
+	api.PUT("/sheets/:id/primary-key", middleware.Authenticate(cfg, authService), allowedSheetHandler.UpdatePrimaryKey)
+	api.PUT("/sheets/:id/search-columns", middleware.Authenticate(cfg, authService), allowedSheetHandler.UpdateSearchColumns)
+	api.PUT("/sheets/:id/origins", middleware.Authenticate(cfg, authService), allowedSheetHandler.UpdateAllowedOrigins)
 
 	// Authentication management (bearer token and basic auth setup)
 	api.GET("/sheets/:id/auth", middleware.Authenticate(cfg, authService), allowedSheetHandler.GetAuthStatus)
 	api.POST("/sheets/:id/auth/type", middleware.Authenticate(cfg, authService), allowedSheetHandler.SetAuthType)
 	api.POST("/sheets/:id/auth/bearer", middleware.Authenticate(cfg, authService), allowedSheetHandler.GenerateBearerToken)
 	api.POST("/sheets/:id/auth/bearer/rotate", middleware.Authenticate(cfg, authService), allowedSheetHandler.RotateBearerToken)
+	api.DELETE("/sheets/:id/auth/bearer/previous", middleware.Authenticate(cfg, authService), allowedSheetHandler.EndBearerTokenGrace)
 	api.POST("/sheets/:id/auth/basic", middleware.Authenticate(cfg, authService), allowedSheetHandler.SetBasicAuth)
 	api.DELETE("/sheets/:id/auth", middleware.Authenticate(cfg, authService), allowedSheetHandler.DisableAuth)
 
+	// Named API keys (many per sheet, each with its own scopes and expiry)
+	api.GET("/sheets/:id/keys", middleware.Authenticate(cfg, authService), allowedSheetHandler.ListAPIKeys)
+	api.POST("/sheets/:id/keys", middleware.Authenticate(cfg, authService), allowedSheetHandler.CreateAPIKey)
+	api.PATCH("/sheets/:id/keys/:key_id", middleware.Authenticate(cfg, authService), allowedSheetHandler.UpdateAPIKey)
+	api.DELETE("/sheets/:id/keys/:key_id", middleware.Authenticate(cfg, authService), allowedSheetHandler.DeleteAPIKey)
+
 	// Sheet access (requires JWT auth + sheet must be registered)
 	sheetHandler := handlers.NewSheetHandler(sheetService)
 	api.POST("/sheets/create", middleware.Authenticate(cfg, authService), sheetHandler.CreateSheet)
@@ -1,22 +1,30 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	"gsheetbase/shared/models"
 	"gsheetbase/shared/repository"
 	"gsheetbase/web/internal/http/middleware"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AllowedSheetHandler struct {
-	repo repository.AllowedSheetRepo
+	repo       repository.AllowedSheetRepo
+	apiKeyRepo repository.APIKeyRepo
 }
 
-func NewAllowedSheetHandler(repo repository.AllowedSheetRepo) *AllowedSheetHandler {
-	return &AllowedSheetHandler{repo: repo}
+func NewAllowedSheetHandler(repo repository.AllowedSheetRepo, apiKeyRepo repository.APIKeyRepo) *AllowedSheetHandler {
+	return &AllowedSheetHandler{repo: repo, apiKeyRepo: apiKeyRepo}
 }
 
 type registerSheetRequest struct {
@@ -176,6 +184,7 @@ func (h *AllowedSheetHandler) Unpublish(c *gin.Context) {
 type updateWriteSettingsRequest struct {
 	AllowWrite     *bool    `json:"allow_write"`
 	AllowedMethods []string `json:"allowed_methods"`
+	ValueInputMode *string  `json:"value_input_mode"` // RAW, USER_ENTERED or SANITIZED; "" restores the default
 }
 
 // UpdateWriteSettings enables/disables write operations for a sheet
@@ -197,6 +206,10 @@ func (h *AllowedSheetHandler) UpdateWriteSettings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
+	if req.ValueInputMode != nil && *req.ValueInputMode != "" && !models.IsValidInputMode(*req.ValueInputMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "value_input_mode must be RAW, USER_ENTERED or SANITIZED"})
+		return
+	}
 
 	// Verify the sheet belongs to the user
 	sheet, err := h.repo.FindByID(c.Request.Context(), middleware.MustParseUUID(sheetID))
@@ -226,9 +239,220 @@ func (h *AllowedSheetHandler) UpdateWriteSettings(c *gin.Context) {
 		}
 	}
 
+	// Update value input mode if provided
+	if req.ValueInputMode != nil {
+		var mode *string
+		if *req.ValueInputMode != "" {
+			mode = req.ValueInputMode
+		}
+		if err := h.repo.UpdateValueInputMode(c.Request.Context(), sheet.ID, mode); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update value input mode"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "write settings updated successfully"})
 }
 
+type updateColumnSchemaRequest struct {
+	Columns models.ColumnSchema `json:"columns"`
+	Strict  *bool               `json:"strict"`
+}
+
+// UpdateColumnSchema sets the column types used by the worker to coerce and validate values.
+// An empty columns object clears the schema so types are inferred from the data.
+// strict rejects writes with unknown fields; when omitted the current setting is kept.
+func (h *AllowedSheetHandler) UpdateColumnSchema(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	sheetID := c.Param("id")
+	if sheetID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sheet id is required"})
+		return
+	}
+
+	var req updateColumnSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+		return
+	}
+
+	if err := req.Columns.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid column schema", "details": err.Error()})
+		return
+	}
+
+	// Verify the sheet belongs to the user
+	sheet, err := h.repo.FindByID(c.Request.Context(), middleware.MustParseUUID(sheetID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sheet not found"})
+		return
+	}
+
+	if sheet.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	strict := sheet.StrictFields
+	if req.Strict != nil {
+		strict = *req.Strict
+	}
+
+	if err := h.repo.UpdateColumnSchema(c.Request.Context(), sheet.ID, req.Columns, strict); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update column schema"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "column schema updated successfully",
+		"columns": req.Columns,
+		"strict":  strict,
+	})
+}
+
+type updatePrimaryKeyRequest struct {
+	Column *string `json:"column"`
+}
+
+// UpdatePrimaryKey sets the column whose values uniquely identify a row.
+// A null or empty column clears it so the sheet row number is used instead.
+func (h *AllowedSheetHandler) UpdatePrimaryKey(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	sheetID := c.Param("id")
+	if sheetID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sheet id is required"})
+		return
+	}
+
+	var req updatePrimaryKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+		return
+	}
+
+	// Verify the sheet belongs to the user
+	sheet, err := h.repo.FindByID(c.Request.Context(), middleware.MustParseUUID(sheetID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sheet not found"})
+		return
+	}
+
+	if sheet.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	if err := h.repo.UpdatePrimaryKeyColumn(c.Request.Context(), sheet.ID, req.Column); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update primary key column"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "primary key column updated successfully",
+		"column":  req.Column,
+	})
+}
+
+type updateSearchColumnsRequest struct {
+	Columns []string `json:"columns"`
+}
+
+// UpdateSearchColumns sets the columns the worker's full-text search (q=) looks at.
+// An empty list searches every column.
+func (h *AllowedSheetHandler) UpdateSearchColumns(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	sheetID := c.Param("id")
+	if sheetID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sheet id is required"})
+		return
+	}
+
+	var req updateSearchColumnsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+		return
+	}
+
+	// Verify the sheet belongs to the user
+	sheet, err := h.repo.FindByID(c.Request.Context(), middleware.MustParseUUID(sheetID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sheet not found"})
+		return
+	}
+
+	if sheet.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
+
+	if err := h.repo.UpdateSearchColumns(c.Request.Context(), sheet.ID, req.Columns); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update search columns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "search columns updated successfully",
+		"columns": req.Columns,
+	})
+}
+
+type updateAllowedOriginsRequest struct {
+	Origins  []string `json:"origins"`
+	Referers []string `json:"referers"`
+}
+
+// UpdateAllowedOrigins sets the browser origins and HTTP referers the worker
+// accepts for the sheet. An empty list accepts any origin or referer.
+func (h *AllowedSheetHandler) UpdateAllowedOrigins(c *gin.Context) {
+	sheet, ok := h.ownedSheet(c)
+	if !ok {
+		return
+	}
+
+	var req updateAllowedOriginsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+		return
+	}
+
+	origins, err := normalizePatterns(req.Origins, models.IsValidOriginPattern, "origin")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	referers, err := normalizePatterns(req.Referers, models.IsValidRefererPattern, "referer")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.UpdateAllowedOrigins(c.Request.Context(), sheet.ID, origins, referers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update allowed origins"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "allowed origins updated successfully",
+		"origins":  origins,
+		"referers": referers,
+	})
+}
+
 // ============================================================================
 // Auth Management Endpoints
 // ============================================================================
@@ -238,10 +462,15 @@ type setAuthTypeRequest struct {
 }
 
 type authStatusResponse struct {
-	AuthType             string `json:"auth_type"`
-	AuthBearerTokenSet   bool   `json:"auth_bearer_token_set"`
-	AuthBasicUsernameSet bool   `json:"auth_basic_username_set"`
-	AuthBasicPasswordSet bool   `json:"auth_basic_password_set"`
+	AuthType              string `json:"auth_type"`
+	AuthBearerTokenSet    bool   `json:"auth_bearer_token_set"`
+	AuthBearerTokenPrefix string `json:"auth_bearer_token_prefix,omitempty"` // e.g. "gskey_ab12…"
+	AuthBasicUsernameSet  bool   `json:"auth_basic_username_set"`
+	AuthBasicPasswordSet  bool   `json:"auth_basic_password_set"`
+
+	// Token replaced by the last rotation, while its grace period lasts
+	PreviousBearerTokenPrefix    string     `json:"previous_bearer_token_prefix,omitempty"`
+	PreviousBearerTokenExpiresAt *time.Time `json:"previous_bearer_token_expires_at,omitempty"`
 }
 
 // GetAuthStatus returns the current auth configuration without exposing sensitive data
@@ -270,10 +499,15 @@ func (h *AllowedSheetHandler) GetAuthStatus(c *gin.Context) {
 	}
 
 	status := authStatusResponse{
-		AuthType:             sheet.AuthType,
-		AuthBearerTokenSet:   sheet.AuthBearerToken != nil,
-		AuthBasicUsernameSet: sheet.AuthBasicUsername != nil,
-		AuthBasicPasswordSet: sheet.AuthBasicPasswordHash != nil,
+		AuthType:              sheet.AuthType,
+		AuthBearerTokenSet:    sheet.AuthBearerTokenDigest != nil || sheet.AuthBearerToken != nil,
+		AuthBearerTokenPrefix: bearerTokenHint(sheet),
+		AuthBasicUsernameSet:  sheet.AuthBasicUsername != nil,
+		AuthBasicPasswordSet:  sheet.AuthBasicPasswordHash != nil,
+	}
+	if exp := sheet.AuthPreviousBearerTokenExpiresAt; exp != nil && exp.After(time.Now()) && sheet.AuthPreviousBearerTokenPrefix != nil {
+		status.PreviousBearerTokenPrefix = *sheet.AuthPreviousBearerTokenPrefix + "…"
+		status.PreviousBearerTokenExpiresAt = exp
 	}
 
 	c.JSON(http.StatusOK, gin.H{"auth": status})
@@ -361,7 +595,11 @@ func (h *AllowedSheetHandler) GenerateBearerToken(c *gin.Context) {
 	}
 
 	// Generate a new bearer token
-	token := repository.GenerateBearerToken()
+	token, err := repository.GenerateBearerToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate bearer token"})
+		return
+	}
 
 	// Update sheet with new bearer token (auth_type stays unchanged or gets set to bearer)
 	authType := "bearer"
@@ -447,12 +685,19 @@ func (h *AllowedSheetHandler) SetBasicAuth(c *gin.Context) {
 	})
 }
 
+// Grace window during which a rotated-out bearer token keeps working
+const (
+	defaultRotationGrace = 24 * time.Hour
+	maxRotationGrace     = 30 * 24 * time.Hour
+)
+
 type rotateTokenRequest struct {
-	// optional: if true, keep the old token active for 24h (for graceful migration)
-	// for now, rotation is immediate
+	// Seconds the old token stays valid after rotation (default 24h, 0 rotates immediately)
+	GracePeriod *int `json:"grace_period" binding:"omitempty,min=0"`
 }
 
-// RotateBearerToken generates a new bearer token and replaces the old one
+// RotateBearerToken generates a new bearer token. The old token keeps working
+// during the grace period so consumers can switch over without downtime.
 func (h *AllowedSheetHandler) RotateBearerToken(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
@@ -466,6 +711,21 @@ func (h *AllowedSheetHandler) RotateBearerToken(c *gin.Context) {
 		return
 	}
 
+	// The body is optional
+	var req rotateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period must be a non-negative number of seconds"})
+		return
+	}
+	grace := defaultRotationGrace
+	if req.GracePeriod != nil {
+		grace = time.Duration(*req.GracePeriod) * time.Second
+	}
+	if grace > maxRotationGrace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("grace_period must be at most %d seconds", int(maxRotationGrace.Seconds()))})
+		return
+	}
+
 	sheet, err := h.repo.FindByID(c.Request.Context(), middleware.MustParseUUID(sheetID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "sheet not found"})
@@ -484,24 +744,52 @@ func (h *AllowedSheetHandler) RotateBearerToken(c *gin.Context) {
 	}
 
 	// Generate a new bearer token
-	newToken := repository.GenerateBearerToken()
+	newToken, err := repository.GenerateBearerToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate bearer token"})
+		return
+	}
 
-	// Update with new token
-	authType := "bearer"
-	err = h.repo.UpdateAuth(c.Request.Context(), sheet.ID, authType, &newToken, nil, nil)
+	var graceUntil *time.Time
+	if grace > 0 {
+		until := time.Now().Add(grace).UTC()
+		graceUntil = &until
+	}
+
+	err = h.repo.RotateBearerToken(c.Request.Context(), sheet.ID, newToken, graceUntil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate bearer token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	resp := gin.H{
 		"token": generateBearerTokenResponse{
 			TokenType: "Bearer",
 			Token:     newToken,
 			ExpiresIn: -1,
 		},
 		"message": "Bearer token rotated successfully (old token is now invalid)",
-	})
+	}
+	if graceUntil != nil {
+		resp["previous_token_expires_at"] = graceUntil
+		resp["message"] = "Bearer token rotated successfully (old token stays valid until " + graceUntil.Format(time.RFC3339) + ")"
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// EndBearerTokenGrace invalidates the previous bearer token before its grace period ends
+func (h *AllowedSheetHandler) EndBearerTokenGrace(c *gin.Context) {
+	sheet, ok := h.ownedSheet(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.EndBearerTokenGrace(c.Request.Context(), sheet.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to end grace period"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "previous bearer token is now invalid"})
 }
 
 type disableAuthRequest struct{}
@@ -541,8 +829,244 @@ func (h *AllowedSheetHandler) DisableAuth(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "authentication disabled (sheet now requires is_public=true to access)"})
 }
 
+// ============================================================================
+// API Key Endpoints
+// ============================================================================
+
+type createAPIKeyRequest struct {
+	Label     string     `json:"label" binding:"required,max=255"`
+	Scopes    []string   `json:"scopes"`     // defaults to read
+	ExpiresAt *time.Time `json:"expires_at"` // optional, RFC 3339
+}
+
+type updateAPIKeyRequest struct {
+	Label     *string         `json:"label" binding:"omitempty,max=255"`
+	Scopes    []string        `json:"scopes"`
+	ExpiresAt json.RawMessage `json:"expires_at"` // null removes the expiry
+}
+
+// ListAPIKeys returns the named API keys of a sheet (without the keys themselves)
+func (h *AllowedSheetHandler) ListAPIKeys(c *gin.Context) {
+	sheet, ok := h.ownedSheet(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyRepo.FindBySheetID(c.Request.Context(), sheet.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list api keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateAPIKey generates a named API key with its own scopes and expiry.
+// Keys are only issued for sheets with auth_type none: on a sheet protected by
+// bearer or basic auth a key would stand in for those credentials.
+func (h *AllowedSheetHandler) CreateAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label is required"})
+		return
+	}
+	if req.Scopes == nil {
+		req.Scopes = []string{models.ScopeRead}
+	}
+	if err := validateScopes(req.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	sheet, ok := h.ownedSheet(c)
+	if !ok {
+		return
+	}
+	if sheet.AuthType != "none" {
+		c.JSON(http.StatusConflict, gin.H{"error": "api keys can only be created for sheets with auth_type none"})
+		return
+	}
+
+	apiKey, key, err := h.apiKeyRepo.Create(c.Request.Context(), sheet.ID, req.Label, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"api_key": apiKey,
+		"key":     key,
+		"message": "API key created successfully (save it securely, it won't be shown again)",
+	})
+}
+
+// UpdateAPIKey changes the label, scopes or expiry of a named API key
+func (h *AllowedSheetHandler) UpdateAPIKey(c *gin.Context) {
+	var req updateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Scopes != nil {
+		if err := validateScopes(req.Scopes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	sheet, ok := h.ownedSheet(c)
+	if !ok {
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+
+	apiKey, err := h.apiKeyRepo.FindByID(c.Request.Context(), sheet.ID, keyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+
+	label := apiKey.Label
+	if req.Label != nil {
+		label = *req.Label
+	}
+	scopes := []string(apiKey.Scopes)
+	if req.Scopes != nil {
+		scopes = req.Scopes
+	}
+	expiresAt := apiKey.ExpiresAt
+	if req.ExpiresAt != nil {
+		if err := json.Unmarshal(req.ExpiresAt, &expiresAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be an RFC 3339 time or null"})
+			return
+		}
+		if expiresAt != nil && !expiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+	}
+
+	apiKey, err = h.apiKeyRepo.Update(c.Request.Context(), sheet.ID, keyID, label, scopes, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update api key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_key": apiKey})
+}
+
+// DeleteAPIKey revokes a named API key; the sheet's other keys keep working
+func (h *AllowedSheetHandler) DeleteAPIKey(c *gin.Context) {
+	sheet, ok := h.ownedSheet(c)
+	if !ok {
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+
+	if _, err := h.apiKeyRepo.FindByID(c.Request.Context(), sheet.ID, keyID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+
+	if err := h.apiKeyRepo.Delete(c.Request.Context(), sheet.ID, keyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke api key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+}
+
 // ============================================================================
 // Helper Functions
 // ============================================================================
 
 // (Token generation moved to shared/repository.GenerateBearerToken)
+
+// ownedSheet loads the sheet named by the :id parameter and checks that it
+// belongs to the authenticated user, answering the request if not
+func (h *AllowedSheetHandler) ownedSheet(c *gin.Context) (models.AllowedSheet, bool) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return models.AllowedSheet{}, false
+	}
+
+	sheetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sheet id is required"})
+		return models.AllowedSheet{}, false
+	}
+
+	sheet, err := h.repo.FindByID(c.Request.Context(), sheetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sheet not found"})
+		return models.AllowedSheet{}, false
+	}
+
+	if sheet.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return models.AllowedSheet{}, false
+	}
+
+	return sheet, true
+}
+
+// validateScopes checks API key scopes: at least one, each read, write or
+// delete, optionally limited to a collection as "<scope>:<collection>"
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("scopes must not be empty")
+	}
+	for _, scope := range scopes {
+		if !models.IsValidScope(scope) {
+			return fmt.Errorf("invalid scope %q: use read, write or delete, optionally as <scope>:<collection>", scope)
+		}
+	}
+	return nil
+}
+
+// normalizePatterns trims origin or referer patterns and their trailing slash,
+// drops blanks and duplicates, and rejects any that valid does not accept
+func normalizePatterns(patterns []string, valid func(string) bool, kind string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		if pattern == "" || seen[pattern] {
+			continue
+		}
+		if !valid(pattern) {
+			return nil, fmt.Errorf("invalid %s %q: use https://example.com or https://*.example.com", kind, pattern)
+		}
+		seen[pattern] = true
+		normalized = append(normalized, pattern)
+	}
+	return normalized, nil
+}
+
+// bearerTokenHint returns the visible prefix of the sheet's bearer token, such
+// as "gskey_ab12…", or "" if it has none
+func bearerTokenHint(sheet models.AllowedSheet) string {
+	switch {
+	case sheet.AuthBearerTokenPrefix != nil:
+		return *sheet.AuthBearerTokenPrefix + "…"
+	case sheet.AuthBearerToken != nil:
+		// Not hashed yet: it will be on first use
+		return repository.TokenPrefix(*sheet.AuthBearerToken) + "…"
+	}
+	return ""
+}
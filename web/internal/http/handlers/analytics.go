@@ -86,9 +86,14 @@ func (h *AnalyticsHandler) GetSheetAnalytics(c *gin.Context) {
 		return
 	}
 
-	// Aggregate by date
+	// Aggregate by date, and by named API key
 	dailyMap := make(map[string]*DailyUsageSummary)
+	keyUsage := make(map[string]int)
 	for _, record := range usageRecords {
+		if record.ApiKeyID != nil {
+			keyUsage[record.ApiKeyID.String()] += record.RequestCount
+		}
+
 		// Group by calendar date (midnight UTC) so counts for the same day
 		// always map to the same key. Use RFC3339 timestamp at 00:00:00Z.
 		d := record.RequestDate.UTC()
@@ -144,6 +149,7 @@ func (h *AnalyticsHandler) GetSheetAnalytics(c *gin.Context) {
 		"start_date":  startDate.UTC().Format(time.RFC3339),
 		"end_date":    endDate.UTC().Format(time.RFC3339),
 		"daily_usage": dailyStats,
+		"key_usage":   keyUsage, // total requests per named API key ID
 	})
 }
 
@@ -45,8 +45,16 @@ type PlanInfo struct {
 
 	// Features
 	CacheMinTTL     int  `json:"cache_min_ttl_seconds"`
+	CacheBypass     bool `json:"cache_bypass"`
 	CustomDomain    bool `json:"custom_domain"`
 	PrioritySupport bool `json:"priority_support"`
+
+	// Conditional requests
+	NotModifiedWeight int `json:"not_modified_weight_percent"`
+
+	// Bulk writes
+	BulkRowsPerUnit int `json:"bulk_rows_per_unit"`
+	MaxBulkRows     int `json:"max_bulk_rows"`
 }
 
 // UsageInfo contains current usage statistics
@@ -90,8 +98,12 @@ func (h *SubscriptionHandler) GetCurrentPlan(c *gin.Context) {
 		MonthlyPrice:       limits.MonthlyPrice,
 		AnnualPrice:        limits.AnnualPrice,
 		CacheMinTTL:        limits.CacheMinTTL,
+		CacheBypass:        limits.CacheBypass,
 		CustomDomain:       limits.CustomDomain,
 		PrioritySupport:    limits.PrioritySupport,
+		NotModifiedWeight:  limits.NotModifiedWeight,
+		BulkRowsPerUnit:    limits.BulkRowsPerUnit,
+		MaxBulkRows:        limits.MaxBulkRows,
 	}
 
 	if user.BillingPeriod != nil {
@@ -184,8 +196,12 @@ func convertPlanToInfo(plan models.SubscriptionPlan) PlanInfo {
 		MonthlyPrice:       limits.MonthlyPrice,
 		AnnualPrice:        limits.AnnualPrice,
 		CacheMinTTL:        limits.CacheMinTTL,
+		CacheBypass:        limits.CacheBypass,
 		CustomDomain:       limits.CustomDomain,
 		PrioritySupport:    limits.PrioritySupport,
+		NotModifiedWeight:  limits.NotModifiedWeight,
+		BulkRowsPerUnit:    limits.BulkRowsPerUnit,
+		MaxBulkRows:        limits.MaxBulkRows,
 	}
 }
 
@@ -25,7 +25,7 @@ interface Sheet {
   allow_write?: boolean
   allowed_methods?: string[]
   auth_type?: string
-  auth_bearer_token?: string
+  auth_bearer_token_prefix?: string
   auth_basic_username?: string
   created_at: string
 }
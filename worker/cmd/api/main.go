@@ -33,42 +33,53 @@ func main() {
 	sheetRepo := repository.NewAllowedSheetRepo(db)
 	userRepo := repository.NewUserRepo(db)
 	usageRepo := repository.NewUsageRepo(db)
+	idempotencyRepo := repository.NewIdempotencyRepo(db)
+	sequenceRepo := repository.NewSequenceRepo(db)
+	apiKeyRepo := repository.NewAPIKeyRepo(db)
 
 	// Optional: Redis and rate limiting (only if REDIS_URL is set)
+	// Without Redis, responses are cached in an in-process LRU instead and
+	// Idempotency-Key responses are kept in Postgres.
 	var rateLimitService *services.RateLimitService
+	var responseCache cache.ResponseCache = cache.NewMemoryResponseCache(cfg.ResponseCacheSize)
+	var idempotencyStore cache.IdempotencyStore = cache.NewDBIdempotencyStore(idempotencyRepo)
 	if cfg.RedisURL != "" {
 		redisClient, err := cache.NewRedisClient(cfg.RedisURL)
 		if err != nil {
 			log.Printf("WARNING: Redis connection failed, rate limiting disabled: %v", err)
 		} else {
 			defer redisClient.Close()
-			log.Println("Redis connected - rate limiting enabled")
+			log.Println("Redis connected - rate limiting and shared response cache enabled")
 			rateLimitService = services.NewRateLimitService(
 				redisClient.GetClient(),
 			)
+			responseCache = cache.NewRedisResponseCache(redisClient.GetClient())
+			idempotencyStore = cache.NewRedisIdempotencyStore(redisClient.GetClient())
 		}
 	} else {
-		log.Println("Redis URL not configured - rate limiting disabled")
+		log.Println("Redis URL not configured - rate limiting disabled, using in-process response cache")
 	}
+	responseCacheTTL := time.Duration(cfg.ResponseCacheTTL) * time.Second
+	idempotencyWindow := time.Duration(cfg.IdempotencyWindow) * time.Second
 
 	// Usage tracker with background workers
 	usageTracker := middleware.NewUsageTracker(usageRepo, cfg.UsageTrackWorkers)
 	defer usageTracker.Shutdown()
 
 	// Handlers
-	sheetHandler := handlers.NewSheetHandler(sheetRepo, userRepo)
+	sheetHandler := handlers.NewSheetHandler(sheetRepo, userRepo, sequenceRepo)
 
 	// Setup Gin
 	r := gin.Default()
 
-	// CORS - permissive for public API
+	// CORS - any origin unless the sheet restricts them (see SheetOriginMiddleware)
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: false,
-		MaxAge:           12 * time.Hour,
+		AllowOriginWithContextFunc: middleware.PreflightOriginFunc(sheetRepo, apiKeyRepo),
+		AllowMethods:               []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:               []string{"Origin", "Content-Type", "Accept", "Authorization", "If-None-Match", "If-Modified-Since", "If-Match", "Idempotency-Key"},
+		ExposeHeaders:              []string{"Content-Length", "Link", "X-Cache", "ETag", "Last-Modified", "Content-Disposition", "Idempotent-Replayed", "X-Auth-Token-Used", "X-Auth-Token-Expires", "X-Row-Version"},
+		AllowCredentials:           false,
+		MaxAge:                     12 * time.Hour,
 	}))
 
 	// Health check
@@ -76,41 +87,57 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
+	// Preflight routes, so the CORS middleware sees the api_key of the sheet
+	// whose allowed origins it checks
+	preflight := func(c *gin.Context) {}
+	for _, path := range []string{"/v1/:api_key", "/v1/:api_key/aggregate", "/v1/:api_key/batch", "/v1", "/v1/aggregate", "/v1/batch"} {
+		r.OPTIONS(path, preflight)
+	}
+
 	// Public API routes with quota enforcement (rate limits + daily/monthly quotas)
 	v1 := r.Group("/v1")
 
 	// Apply SheetAuthMiddleware to resolve sheets by api_key, Bearer, or Basic auth
-	v1.Use(middleware.SheetAuthMiddleware(sheetRepo))
+	v1.Use(middleware.SheetAuthMiddleware(sheetRepo, apiKeyRepo))
+	v1.Use(middleware.SheetOriginMiddleware())
 
 	// Group for handling routes that may have `:api_key` param (backward compat)
 	// or rely on Authorization header (new auth types)
 	authService := services.NewAuthService()
 	apiKeyGroup := v1.Group(":api_key")
 	if rateLimitService != nil {
-		apiKeyGroup.Use(middleware.QuotaEnforcementMiddleware(rateLimitService, usageRepo, userRepo, sheetRepo))
+		apiKeyGroup.Use(middleware.QuotaEnforcementMiddleware(rateLimitService, usageRepo, userRepo))
 	}
 	apiKeyGroup.Use(middleware.UsageTrackingMiddleware(usageTracker))
-	apiKeyGroup.Use(middleware.AccessTokenEnsureMiddleware(sheetRepo, userRepo, authService, cfg.GoogleClientID, cfg.GoogleClientSecret))
+	apiKeyGroup.Use(middleware.ResponseCacheMiddleware(responseCache, userRepo, responseCacheTTL))
+	apiKeyGroup.Use(middleware.IdempotencyMiddleware(idempotencyStore, idempotencyWindow))
+	apiKeyGroup.Use(middleware.AccessTokenEnsureMiddleware(userRepo, authService, cfg.GoogleClientID, cfg.GoogleClientSecret))
 
 	apiKeyGroup.GET("", sheetHandler.GetPublic)
 	apiKeyGroup.POST("", sheetHandler.PostPublic)
 	apiKeyGroup.PUT("", sheetHandler.PutPublic)
 	apiKeyGroup.PATCH("", sheetHandler.PatchPublic)
 	apiKeyGroup.DELETE("", sheetHandler.DeletePublic)
+	apiKeyGroup.GET("/aggregate", sheetHandler.AggregatePublic)
+	apiKeyGroup.POST("/batch", sheetHandler.BatchPublic)
 
 	// Also register routes without :api_key param to support Authorization header auth
 	authOnlyGroup := v1.Group("")
 	if rateLimitService != nil {
-		authOnlyGroup.Use(middleware.QuotaEnforcementMiddleware(rateLimitService, usageRepo, userRepo, sheetRepo))
+		authOnlyGroup.Use(middleware.QuotaEnforcementMiddleware(rateLimitService, usageRepo, userRepo))
 	}
 	authOnlyGroup.Use(middleware.UsageTrackingMiddleware(usageTracker))
-	authOnlyGroup.Use(middleware.AccessTokenEnsureMiddleware(sheetRepo, userRepo, authService, cfg.GoogleClientID, cfg.GoogleClientSecret))
+	authOnlyGroup.Use(middleware.ResponseCacheMiddleware(responseCache, userRepo, responseCacheTTL))
+	authOnlyGroup.Use(middleware.IdempotencyMiddleware(idempotencyStore, idempotencyWindow))
+	authOnlyGroup.Use(middleware.AccessTokenEnsureMiddleware(userRepo, authService, cfg.GoogleClientID, cfg.GoogleClientSecret))
 
 	authOnlyGroup.GET("", sheetHandler.GetPublic)
 	authOnlyGroup.POST("", sheetHandler.PostPublic)
 	authOnlyGroup.PUT("", sheetHandler.PutPublic)
 	authOnlyGroup.PATCH("", sheetHandler.PatchPublic)
 	authOnlyGroup.DELETE("", sheetHandler.DeletePublic)
+	authOnlyGroup.GET("/aggregate", sheetHandler.AggregatePublic)
+	authOnlyGroup.POST("/batch", sheetHandler.BatchPublic)
 
 	addr := ":" + cfg.Port
 	log.Printf("Worker API listening on %s", addr)
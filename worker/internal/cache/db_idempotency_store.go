@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gsheetbase/shared/repository"
+
+	"github.com/google/uuid"
+)
+
+// DBIdempotencyStore keeps idempotency records in Postgres, used when Redis is not configured
+type DBIdempotencyStore struct {
+	repo repository.IdempotencyRepo
+}
+
+// NewDBIdempotencyStore creates a Postgres-backed idempotency store
+func NewDBIdempotencyStore(repo repository.IdempotencyRepo) *DBIdempotencyStore {
+	return &DBIdempotencyStore{repo: repo}
+}
+
+// Reserve claims the key, or returns the record stored under it
+func (s *DBIdempotencyStore) Reserve(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, lockTTL time.Duration) (*IdempotencyRecord, bool, error) {
+	entry, reserved, err := s.repo.Reserve(ctx, sheetID, key, fingerprint, time.Now().Add(lockTTL))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if reserved {
+		return nil, true, nil
+	}
+
+	record := &IdempotencyRecord{Fingerprint: entry.Fingerprint}
+	if entry.ResponseStatus != nil {
+		resp := &CachedResponse{Status: *entry.ResponseStatus, Body: entry.ResponseBody}
+		if len(entry.ResponseHeaders) > 0 {
+			if err := json.Unmarshal(entry.ResponseHeaders, &resp.Headers); err != nil {
+				return nil, false, fmt.Errorf("failed to decode idempotency record: %w", err)
+			}
+		}
+		record.Response = resp
+	}
+	return record, false, nil
+}
+
+// Complete stores the response for the replay window
+func (s *DBIdempotencyStore) Complete(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, resp *CachedResponse, ttl time.Duration) error {
+	headers, err := json.Marshal(resp.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to encode response headers: %w", err)
+	}
+	return s.repo.Complete(ctx, sheetID, key, resp.Status, headers, resp.Body, time.Now().Add(ttl))
+}
+
+// Release forgets a claimed key
+func (s *DBIdempotencyStore) Release(ctx context.Context, sheetID uuid.UUID, key string) error {
+	return s.repo.Release(ctx, sheetID, key)
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ComputeETag returns a strong entity tag for a response body
+func ComputeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ETagMatches reports whether an If-None-Match header matches the entity tag.
+// Uses weak comparison as required for If-None-Match (W/ prefixes are ignored).
+func ETagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// NotModified reports whether a GET with these request headers can be answered
+// with 304 Not Modified. If-None-Match is compared with the entity tag when
+// present; otherwise If-Modified-Since is compared with the Last-Modified time.
+func NotModified(header http.Header, etag, lastModified string) bool {
+	if ifNoneMatch := header.Get("If-None-Match"); ifNoneMatch != "" {
+		return ETagMatches(ifNoneMatch, etag)
+	}
+	since, err := http.ParseTime(header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := ComputeETag([]byte(`{"data":[]}`))
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"same tag", etag, true},
+		{"weak tag", "W/" + etag, true},
+		{"one of a list", `"other", ` + etag, true},
+		{"wildcard", "*", true},
+		{"other tag", `"other"`, false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ETagMatches(tt.ifNoneMatch, etag); got != tt.want {
+				t.Errorf("ETagMatches(%q) = %v, want %v", tt.ifNoneMatch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	const etag = `"abc"`
+	const lastModified = "Fri, 16 Oct 2026 12:00:00 GMT"
+
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		want            bool
+	}{
+		{"no conditions", "", "", false},
+		{"etag matches", etag, "", true},
+		{"etag differs", `"xyz"`, "", false},
+		{"not modified since", "", "Fri, 16 Oct 2026 12:00:00 GMT", true},
+		{"since a later time", "", "Fri, 16 Oct 2026 13:00:00 GMT", true},
+		{"modified since", "", "Fri, 16 Oct 2026 11:59:59 GMT", false},
+		{"invalid date", "", "yesterday", false},
+		{"etag wins over date", `"xyz"`, "Fri, 16 Oct 2026 13:00:00 GMT", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.ifNoneMatch != "" {
+				header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModifiedSince != "" {
+				header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+			if got := NotModified(header, etag, lastModified); got != tt.want {
+				t.Errorf("NotModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyRecord is what is stored for an Idempotency-Key
+type IdempotencyRecord struct {
+	Fingerprint string          `json:"fingerprint"`
+	Response    *CachedResponse `json:"response,omitempty"` // nil while the first request is in flight
+}
+
+// IdempotencyStore remembers worker write responses by Idempotency-Key, per sheet.
+//
+// Reserve claims a key for lockTTL and reports true, or returns the record
+// already stored under it. Complete stores the response for the replay window;
+// Release forgets a claimed key so the request can be retried.
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, lockTTL time.Duration) (*IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, resp *CachedResponse, ttl time.Duration) error
+	Release(ctx context.Context, sheetID uuid.UUID, key string) error
+}
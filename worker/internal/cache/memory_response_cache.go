@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryResponseCache is an in-process LRU response cache used when Redis is not configured
+type MemoryResponseCache struct {
+	mu          sync.Mutex
+	maxEntries  int
+	order       *list.List
+	entries     map[string]*list.Element
+	generations map[uuid.UUID]uint64
+}
+
+type memoryEntry struct {
+	key       string
+	resp      *CachedResponse
+	expiresAt time.Time
+}
+
+// NewMemoryResponseCache creates an LRU cache holding at most maxEntries responses
+func NewMemoryResponseCache(maxEntries int) *MemoryResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryResponseCache{
+		maxEntries:  maxEntries,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+		generations: make(map[uuid.UUID]uint64),
+	}
+}
+
+// entryKey scopes a key to the sheet's current generation.
+// Caller must hold the lock.
+func (m *MemoryResponseCache) entryKey(sheetID uuid.UUID, key string) string {
+	return fmt.Sprintf("%s:%d:%s", sheetID, m.generations[sheetID], key)
+}
+
+// Get returns a cached response if present and not expired
+func (m *MemoryResponseCache) Get(ctx context.Context, sheetID uuid.UUID, key string) (*CachedResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[m.entryKey(sheetID, key)]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if time.Now().After(entry.expiresAt) {
+		m.removeElement(elem)
+		return nil, false, nil
+	}
+
+	m.order.MoveToFront(elem)
+	return entry.resp, true, nil
+}
+
+// Set stores a response, evicting the least recently used entries when full
+func (m *MemoryResponseCache) Set(ctx context.Context, sheetID uuid.UUID, key string, resp *CachedResponse, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fullKey := m.entryKey(sheetID, key)
+	entry := &memoryEntry{key: fullKey, resp: resp, expiresAt: time.Now().Add(ttl)}
+
+	if elem, ok := m.entries[fullKey]; ok {
+		elem.Value = entry
+		m.order.MoveToFront(elem)
+		return nil
+	}
+
+	m.entries[fullKey] = m.order.PushFront(entry)
+	for m.order.Len() > m.maxEntries {
+		m.removeElement(m.order.Back())
+	}
+	return nil
+}
+
+// Invalidate drops every cached response for the sheet by bumping its generation.
+// Stale entries are never read again and age out of the LRU.
+func (m *MemoryResponseCache) Invalidate(ctx context.Context, sheetID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.generations[sheetID]++
+	return nil
+}
+
+// removeElement deletes an entry. Caller must hold the lock.
+func (m *MemoryResponseCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*memoryEntry)
+	delete(m.entries, entry.key)
+	m.order.Remove(elem)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisIdempotencyStore keeps idempotency records in Redis so all worker instances share them
+type RedisIdempotencyStore struct {
+	redis *redis.Client
+}
+
+// NewRedisIdempotencyStore creates a Redis-backed idempotency store
+func NewRedisIdempotencyStore(redisClient *redis.Client) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{redis: redisClient}
+}
+
+func idempotencyKey(sheetID uuid.UUID, key string) string {
+	return fmt.Sprintf("idempotency:%s:%s", sheetID, key)
+}
+
+// Reserve claims the key with SET NX, or returns the record stored under it
+func (r *RedisIdempotencyStore) Reserve(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, lockTTL time.Duration) (*IdempotencyRecord, bool, error) {
+	data, err := json.Marshal(IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+
+	fullKey := idempotencyKey(sheetID, key)
+	ok, err := r.redis.SetNX(ctx, fullKey, data, lockTTL).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if ok {
+		return nil, true, nil
+	}
+
+	stored, err := r.redis.Get(ctx, fullKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Expired between the two calls; try once more
+		ok, err = r.redis.SetNX(ctx, fullKey, data, lockTTL).Result()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if !ok {
+			return nil, false, errors.New("failed to reserve idempotency key: concurrent request")
+		}
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read idempotency record: %w", err)
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal(stored, &record); err != nil {
+		return nil, false, fmt.Errorf("failed to decode idempotency record: %w", err)
+	}
+	return &record, false, nil
+}
+
+// Complete stores the response for the replay window
+func (r *RedisIdempotencyStore) Complete(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, resp *CachedResponse, ttl time.Duration) error {
+	data, err := json.Marshal(IdempotencyRecord{Fingerprint: fingerprint, Response: resp})
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+	return r.redis.Set(ctx, idempotencyKey(sheetID, key), data, ttl).Err()
+}
+
+// Release forgets a claimed key
+func (r *RedisIdempotencyStore) Release(ctx context.Context, sheetID uuid.UUID, key string) error {
+	return r.redis.Del(ctx, idempotencyKey(sheetID, key)).Err()
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisResponseCache stores responses in Redis so all worker instances share them
+type RedisResponseCache struct {
+	redis *redis.Client
+}
+
+// NewRedisResponseCache creates a Redis-backed response cache
+func NewRedisResponseCache(redisClient *redis.Client) *RedisResponseCache {
+	return &RedisResponseCache{redis: redisClient}
+}
+
+// generationKey holds a counter that is bumped to invalidate a sheet's entries
+func generationKey(sheetID uuid.UUID) string {
+	return fmt.Sprintf("resp_cache_gen:%s", sheetID)
+}
+
+// entryKey scopes a key to the sheet's current generation
+func (r *RedisResponseCache) entryKey(ctx context.Context, sheetID uuid.UUID, key string) (string, error) {
+	gen, err := r.redis.Get(ctx, generationKey(sheetID)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", fmt.Errorf("failed to read cache generation: %w", err)
+	}
+	return fmt.Sprintf("resp_cache:%s:%d:%s", sheetID, gen, key), nil
+}
+
+// Get returns a cached response if present
+func (r *RedisResponseCache) Get(ctx context.Context, sheetID uuid.UUID, key string) (*CachedResponse, bool, error) {
+	fullKey, err := r.entryKey(ctx, sheetID, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := r.redis.Get(ctx, fullKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cached response: %w", err)
+	}
+
+	var resp CachedResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false, fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	return &resp, true, nil
+}
+
+// Set stores a response with the given TTL
+func (r *RedisResponseCache) Set(ctx context.Context, sheetID uuid.UUID, key string, resp *CachedResponse, ttl time.Duration) error {
+	fullKey, err := r.entryKey(ctx, sheetID, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	return r.redis.Set(ctx, fullKey, data, ttl).Err()
+}
+
+// Invalidate drops every cached response for the sheet by bumping its generation.
+// Old entries are never read again and expire with their TTL.
+func (r *RedisResponseCache) Invalidate(ctx context.Context, sheetID uuid.UUID) error {
+	return r.redis.Incr(ctx, generationKey(sheetID)).Err()
+}
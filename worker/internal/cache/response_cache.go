@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CachedResponse is a stored GET response
+type CachedResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    []byte            `json:"body"`
+}
+
+// ResponseCache stores worker GET responses per sheet.
+//
+// Entries are grouped by sheet so a write through the worker can invalidate
+// every cached read of that sheet with a single call.
+type ResponseCache interface {
+	Get(ctx context.Context, sheetID uuid.UUID, key string) (*CachedResponse, bool, error)
+	Set(ctx context.Context, sheetID uuid.UUID, key string, resp *CachedResponse, ttl time.Duration) error
+	Invalidate(ctx context.Context, sheetID uuid.UUID) error
+}
@@ -14,6 +14,9 @@ type Config struct {
 	UsageTrackWorkers  int
 	GoogleClientID     string
 	GoogleClientSecret string
+	ResponseCacheTTL   int // Default response cache TTL in seconds (clamped to plan minimum)
+	ResponseCacheSize  int // Max entries in the in-process cache when Redis is not configured
+	IdempotencyWindow  int // How long Idempotency-Key responses are replayed, in seconds
 }
 
 func Load() (*Config, error) {
@@ -27,6 +30,9 @@ func Load() (*Config, error) {
 		UsageTrackWorkers:  getEnvInt("USAGE_TRACK_WORKERS", 3),
 		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
+		ResponseCacheTTL:   getEnvInt("RESPONSE_CACHE_TTL", 60),
+		ResponseCacheSize:  getEnvInt("RESPONSE_CACHE_SIZE", 1000),
+		IdempotencyWindow:  getEnvInt("IDEMPOTENCY_WINDOW", 86400),
 	}, nil
 }
 
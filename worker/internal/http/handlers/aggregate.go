@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/worker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// aggregateFunctions lists the functions accepted in the aggregate parameter
+var aggregateFunctions = map[string]bool{
+	"count":    true,
+	"sum":      true,
+	"avg":      true,
+	"min":      true,
+	"max":      true,
+	"distinct": true,
+}
+
+// aggregatePattern matches "fn" or "fn(field)"
+var aggregatePattern = regexp.MustCompile(`^([a-z]+)(?:\((.*)\))?$`)
+
+// aggregateSpec is one parsed aggregate such as sum(price)
+type aggregateSpec struct {
+	fn    string
+	field string
+	name  string // key in the result row, e.g. "sum_price"
+}
+
+// aggregateState accumulates one aggregate over the rows of a group
+type aggregateState struct {
+	count    int
+	sum      float64
+	min      interface{}
+	max      interface{}
+	distinct []interface{}
+	seen     map[string]bool
+}
+
+// aggregateGroup holds the group_by values and aggregate states of one group
+type aggregateGroup struct {
+	values []interface{}
+	states []*aggregateState
+}
+
+// AggregatePublic handles GET /v1/:api_key/aggregate?collection=Sheet1&aggregate=count,sum(price),avg(price)&group_by=city&having={"count":{"$gt":1}}
+func (h *SheetHandler) AggregatePublic(c *gin.Context) {
+	// Parse query params
+	collection := c.Query("collection")
+	where := c.Query("where")
+	groupBy := parseGroupBy(c.Query("group_by"))
+	orderBy := c.Query("orderBy")
+
+	specs, err := parseAggregates(c.DefaultQuery("aggregate", "count"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid aggregate", "details": err.Error()})
+		return
+	}
+
+	filter, err := parseFilter(where)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
+		return
+	}
+
+	having, err := parseFilter(c.Query("having"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid having filter", "details": err.Error()})
+		return
+	}
+
+	sortKeys, err := parseOrderBy(orderBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid orderBy", "details": err.Error()})
+		return
+	}
+
+	// The sheet was resolved by SheetAuthMiddleware (api_key or Authorization header)
+	sheet, ok := middleware.SheetFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet not resolved"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), sheet.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user credentials"})
+		return
+	}
+
+	if user.GoogleAccessToken == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet owner needs to reconnect Google account"})
+		return
+	}
+
+	// Headerless sheets address columns by letter or 0-based index
+	useHeader := sheet.UseFirstRowAsHeader
+	if !useHeader {
+		if err := positionalAggregates(specs, groupBy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid aggregate", "details": err.Error()})
+			return
+		}
+		if err := positionalFilter(filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
+			return
+		}
+	}
+
+	// Determine range
+	fetchRange := collection
+	if fetchRange == "" && sheet.DefaultRange != nil {
+		fetchRange = *sheet.DefaultRange
+	}
+	if fetchRange == "" {
+		fetchRange = "Sheet1"
+	}
+	if !requireScope(c, models.ScopeRead, fetchRange) {
+		return
+	}
+
+	// Fetch sheet data
+	data, err := h.fetchSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, fetchRange)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet data", "details": err.Error()})
+		return
+	}
+	fetchedAt := time.Now()
+
+	rows := sheetRows(data, useHeader)
+	schema := resolveColumnSchema(sheet.ColumnSchema, rows)
+	rows = coerceRows(rows, schema)
+
+	results, err := aggregateRows(filterRows(rows, filter), groupBy, specs, schema)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid aggregate", "details": err.Error()})
+		return
+	}
+	results = orderRows(filterRows(results, having), sortKeys, nil)
+
+	writeConditionalJSON(c, gin.H{"data": results}, fetchedAt, user.GetPlanLimits())
+}
+
+// parseGroupBy splits a comma-separated group_by list
+func parseGroupBy(groupBy string) []string {
+	if strings.TrimSpace(groupBy) == "" {
+		return nil
+	}
+	fields := strings.Split(groupBy, ",")
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return fields
+}
+
+// parseAggregates parses "count,sum(price),distinct(city)". A bare count
+// counts rows; every other function takes a field.
+func parseAggregates(expr string) ([]aggregateSpec, error) {
+	var specs []aggregateSpec
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		m := aggregatePattern.FindStringSubmatch(strings.ToLower(part))
+		if m == nil || !aggregateFunctions[m[1]] {
+			return nil, fmt.Errorf("unsupported aggregate %q (use count, sum, avg, min, max or distinct)", part)
+		}
+		spec := aggregateSpec{fn: m[1], name: m[1]}
+		if open := strings.Index(part, "("); open >= 0 {
+			// Keep the field's original case
+			spec.field = strings.TrimSpace(part[open+1 : len(part)-1])
+			if spec.field == "" {
+				return nil, fmt.Errorf("%s() requires a field", spec.fn)
+			}
+			spec.name = spec.fn + "_" + spec.field
+		} else if spec.fn != "count" {
+			return nil, fmt.Errorf("%s requires a field, e.g. %s(price)", spec.fn, spec.fn)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// positionalAggregates rewrites aggregate and group_by fields to column letters
+func positionalAggregates(specs []aggregateSpec, groupBy []string) error {
+	for i := range specs {
+		if specs[i].field == "" {
+			continue
+		}
+		col, err := parseColumnRef(specs[i].field)
+		if err != nil {
+			return err
+		}
+		specs[i].field = col
+		specs[i].name = specs[i].fn + "_" + col
+	}
+	for i, f := range groupBy {
+		col, err := parseColumnRef(f)
+		if err != nil {
+			return err
+		}
+		groupBy[i] = col
+	}
+	return nil
+}
+
+// aggregateRows groups rows by the group_by fields and computes each aggregate.
+// Groups are returned in order of first appearance; without group_by a single
+// row summarizes every row. sum and avg require a number or integer column.
+func aggregateRows(rows []map[string]interface{}, groupBy []string, specs []aggregateSpec, schema models.ColumnSchema) ([]map[string]interface{}, error) {
+	for _, spec := range specs {
+		if spec.fn != "sum" && spec.fn != "avg" {
+			continue
+		}
+		if t := schema[spec.field].Type; t != models.ColumnNumber && t != models.ColumnInteger {
+			return nil, fmt.Errorf("%s(%s) requires a numeric column", spec.fn, spec.field)
+		}
+	}
+
+	var order []string
+	groups := make(map[string]*aggregateGroup)
+	newGroup := func(values []interface{}) *aggregateGroup {
+		g := &aggregateGroup{values: values, states: make([]*aggregateState, len(specs))}
+		for i := range g.states {
+			g.states[i] = &aggregateState{seen: make(map[string]bool)}
+		}
+		return g
+	}
+	if len(groupBy) == 0 {
+		order = append(order, "")
+		groups[""] = newGroup(nil)
+	}
+
+	for _, row := range rows {
+		values := make([]interface{}, len(groupBy))
+		for i, f := range groupBy {
+			values[i] = row[f]
+		}
+		b, _ := json.Marshal(values)
+		key := string(b)
+		if len(groupBy) == 0 {
+			key = ""
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = newGroup(values)
+			groups[key] = g
+			order = append(order, key)
+		}
+		for i, spec := range specs {
+			g.states[i].add(spec, row, schema)
+		}
+	}
+
+	results := make([]map[string]interface{}, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		result := make(map[string]interface{}, len(groupBy)+len(specs))
+		for i, f := range groupBy {
+			result[f] = g.values[i]
+		}
+		for i, spec := range specs {
+			result[spec.name] = g.states[i].result(spec, schema)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// add folds one row into the aggregate. Empty cells are skipped by every
+// function except a bare count.
+func (s *aggregateState) add(spec aggregateSpec, row map[string]interface{}, schema models.ColumnSchema) {
+	if spec.field == "" {
+		s.count++
+		return
+	}
+	value := row[spec.field]
+	if isEmptyValue(value) {
+		return
+	}
+
+	switch spec.fn {
+	case "count":
+		s.count++
+	case "sum", "avg":
+		if f, ok := models.NumericValue(value); ok {
+			s.sum += f
+			s.count++
+		}
+	case "min":
+		if s.min == nil || compareTyped(value, s.min, schema[spec.field].Type) < 0 {
+			s.min = value
+		}
+	case "max":
+		if s.max == nil || compareTyped(value, s.max, schema[spec.field].Type) > 0 {
+			s.max = value
+		}
+	case "distinct":
+		b, _ := json.Marshal(value)
+		if !s.seen[string(b)] {
+			s.seen[string(b)] = true
+			s.distinct = append(s.distinct, value)
+		}
+	}
+}
+
+// result returns the final value of the aggregate
+func (s *aggregateState) result(spec aggregateSpec, schema models.ColumnSchema) interface{} {
+	switch spec.fn {
+	case "sum":
+		if schema[spec.field].Type == models.ColumnInteger {
+			return int64(s.sum)
+		}
+		return s.sum
+	case "avg":
+		if s.count == 0 {
+			return nil
+		}
+		return s.sum / float64(s.count)
+	case "min":
+		return s.min
+	case "max":
+		return s.max
+	case "distinct":
+		if s.distinct == nil {
+			return []interface{}{}
+		}
+		return s.distinct
+	}
+	return s.count
+}
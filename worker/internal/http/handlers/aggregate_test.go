@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"gsheetbase/shared/models"
+)
+
+func TestParseAggregates(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    []aggregateSpec
+		wantErr bool
+	}{
+		{"count", []aggregateSpec{{fn: "count", name: "count"}}, false},
+		{"count, sum(Price)", []aggregateSpec{{fn: "count", name: "count"}, {fn: "sum", field: "Price", name: "sum_Price"}}, false},
+		{"COUNT(city)", []aggregateSpec{{fn: "count", field: "city", name: "count_city"}}, false},
+		{"sum", nil, true},
+		{"avg()", nil, true},
+		{"median(price)", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parseAggregates(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAggregates(%q) err = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAggregates(%q) = %+v, want %+v", tt.expr, got, tt.want)
+			}
+		})
+	}
+
+	if got := parseGroupBy(" city , state"); !reflect.DeepEqual(got, []string{"city", "state"}) {
+		t.Errorf("parseGroupBy = %v", got)
+	}
+	if got := parseGroupBy(""); got != nil {
+		t.Errorf("parseGroupBy(\"\") = %v, want nil", got)
+	}
+}
+
+func TestAggregateRows(t *testing.T) {
+	schema := models.ColumnSchema{
+		"price": {Type: models.ColumnNumber},
+		"qty":   {Type: models.ColumnInteger},
+	}
+	rows := []map[string]interface{}{
+		{"city": "Oslo", "price": float64(10), "qty": int64(1)},
+		{"city": "Rome", "price": float64(4), "qty": int64(2)},
+		{"city": "Oslo", "price": float64(20), "qty": int64(3)},
+		{"city": "Oslo", "price": nil, "qty": int64(4)},
+	}
+	specs, err := parseAggregates("count,count(price),sum(qty),avg(price),min(price),max(price),distinct(city)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		groupBy []string
+		want    []map[string]interface{}
+	}{
+		{
+			name: "no group_by summarizes every row",
+			want: []map[string]interface{}{{
+				"count": 4, "count_price": 3, "sum_qty": int64(10), "avg_price": float64(34) / 3,
+				"min_price": float64(4), "max_price": float64(20), "distinct_city": []interface{}{"Oslo", "Rome"},
+			}},
+		},
+		{
+			name:    "groups in order of first appearance",
+			groupBy: []string{"city"},
+			want: []map[string]interface{}{
+				{
+					"city": "Oslo", "count": 3, "count_price": 2, "sum_qty": int64(8), "avg_price": float64(15),
+					"min_price": float64(10), "max_price": float64(20), "distinct_city": []interface{}{"Oslo"},
+				},
+				{
+					"city": "Rome", "count": 1, "count_price": 1, "sum_qty": int64(2), "avg_price": float64(4),
+					"min_price": float64(4), "max_price": float64(4), "distinct_city": []interface{}{"Rome"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := aggregateRows(rows, tt.groupBy, specs, schema)
+			if err != nil {
+				t.Fatalf("aggregateRows err = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("aggregateRows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := aggregateRows(rows, nil, []aggregateSpec{{fn: "sum", field: "city", name: "sum_city"}}, schema); err == nil {
+		t.Error("aggregateRows summed a string column")
+	}
+}
@@ -0,0 +1,643 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/worker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/sheets/v4"
+)
+
+// maxBatchOperations caps the operations in one batch request
+const maxBatchOperations = 100
+
+// batchOperation is one insert, update or delete of a batch request
+type batchOperation struct {
+	Op         string                 `json:"op"`
+	Collection string                 `json:"collection"`
+	Data       json.RawMessage        `json:"data"`
+	Where      map[string]interface{} `json:"where"`
+	Limit      *int                   `json:"limit"`
+}
+
+// batchOperationMethods maps each operation to the HTTP methods that enable it
+var batchOperationMethods = map[string][]string{
+	"insert": {"POST"},
+	"update": {"PATCH", "PUT"},
+	"delete": {"DELETE"},
+}
+
+// batchTable is a collection as the batch leaves it after the operations
+// applied so far, so later operations see the effect of earlier ones
+type batchTable struct {
+	tabID     int64
+	headers   []interface{}
+	values    [][]interface{} // data rows, without the header row
+	firstRow  int             // 1-based sheet row of values[0]
+	schema    models.ColumnSchema
+	generator *rowGenerator
+}
+
+// BatchPublic handles POST /v1/:api_key/batch - apply an ordered list of
+// insert, update and delete operations across the collections of the
+// spreadsheet. Every operation is validated (against the state the earlier
+// operations leave behind) before anything is written, then all changes are
+// sent as one Spreadsheets.BatchUpdate, which Google applies atomically.
+func (h *SheetHandler) BatchPublic(c *gin.Context) {
+	var req struct {
+		Operations []batchOperation `json:"operations" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+		return
+	}
+	if len(req.Operations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "operations must contain at least one operation"})
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a batch can contain at most %d operations", maxBatchOperations)})
+		return
+	}
+	for i, op := range req.Operations {
+		if _, ok := batchOperationMethods[op.Op]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid operation", "details": fmt.Sprintf("operation %d: op must be insert, update or delete", i)})
+			return
+		}
+	}
+
+	// The sheet was resolved by SheetAuthMiddleware (api_key or Authorization header)
+	sheet, ok := middleware.SheetFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet not resolved"})
+		return
+	}
+
+	// Every operation must be enabled for the sheet
+	for i, op := range req.Operations {
+		allowed := false
+		for _, method := range batchOperationMethods[op.Op] {
+			allowed = allowed || isMethodAllowed(sheet.AllowedMethods, method)
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("operation %d: %s is not enabled for this sheet", i, op.Op)})
+			return
+		}
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), sheet.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user credentials"})
+		return
+	}
+
+	if user.GoogleAccessToken == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet owner needs to reconnect Google account"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	srv, err := getSheetsService(ctx, *user.GoogleAccessToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to connect to Google Sheets", "details": err.Error()})
+		return
+	}
+	tabs, err := sheetTabIDs(srv, sheet.SheetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch spreadsheet", "details": err.Error()})
+		return
+	}
+
+	// Resolve and fetch every collection the operations touch
+	collections := make([]string, len(req.Operations))
+	loaded := make(map[string]*batchTable)
+	var opErrors []gin.H
+	for i, op := range req.Operations {
+		// Determine the collection (fallback to the default range)
+		collection := op.Collection
+		if collection == "" && sheet.DefaultRange != nil {
+			collection = *sheet.DefaultRange
+		}
+		if collection == "" {
+			collection = "Sheet1"
+		}
+		if !requireScope(c, models.ScopeForMethod(batchOperationMethods[op.Op][0]), collection) {
+			return
+		}
+		collections[i] = collection
+
+		if strings.Contains(collection, "!") {
+			opErrors = append(opErrors, gin.H{"index": i, "details": fmt.Sprintf("collection %q must be a tab name; ranges are not supported in a batch", collection)})
+			continue
+		}
+		if _, ok := loaded[collection]; ok {
+			continue
+		}
+		table, err := h.loadBatchTable(ctx, *user.GoogleAccessToken, sheet, collection, tabs)
+		if err != nil {
+			opErrors = append(opErrors, gin.H{"index": i, "details": err.Error()})
+			continue
+		}
+		loaded[collection] = table
+	}
+	if len(opErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to validate batch", "errors": opErrors})
+		return
+	}
+
+	// Validate the whole batch with auto-increment values counted locally, so
+	// a rejected batch reserves none from the sequence
+	limits := user.GetPlanLimits()
+	check := runBatch(req.Operations, collections, loaded, sheet, limits, func(string) idSequence { return nil })
+	if len(check.errors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to validate batch", "errors": check.errors})
+		return
+	}
+	if exceedsQuota(c, check.units) {
+		return
+	}
+
+	// Build the changes again, now drawing auto-increment values from the sequence
+	run := runBatch(req.Operations, collections, loaded, sheet, limits, func(collection string) idSequence {
+		return h.sequence(ctx, sheet, collection)
+	})
+	for _, table := range run.tables {
+		if generatorFailed(c, table.generator) {
+			return
+		}
+	}
+	if len(run.errors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to validate batch", "errors": run.errors})
+		return
+	}
+
+	if len(run.requests) > 0 {
+		batch := &sheets.BatchUpdateSpreadsheetRequest{Requests: run.requests}
+		if _, err := srv.Spreadsheets.BatchUpdate(sheet.SheetID, batch).Do(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply batch", "details": err.Error()})
+			return
+		}
+	}
+
+	c.Set("usage_units", run.units)
+	c.JSON(http.StatusOK, gin.H{"results": run.results})
+}
+
+// loadBatchTable fetches a collection (a whole tab) and resolves its tab ID
+func (h *SheetHandler) loadBatchTable(ctx context.Context, accessToken string, sheet models.AllowedSheet, collection string, tabs map[string]int64) (*batchTable, error) {
+	tabID, ok := tabs[strings.Trim(collection, "'")]
+	if !ok {
+		return nil, fmt.Errorf("collection %q not found", collection)
+	}
+
+	data, err := h.fetchSheetData(ctx, accessToken, sheet.SheetID, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collection %q: %v", collection, err)
+	}
+
+	useHeader := sheet.UseFirstRowAsHeader
+	headerRows := headerRowCount(useHeader)
+	table := &batchTable{
+		tabID:    tabID,
+		firstRow: 1 + headerRows,
+	}
+	if useHeader {
+		if len(data) == 0 {
+			return nil, fmt.Errorf("collection %q has no header row", collection)
+		}
+		table.headers = data[0]
+	} else {
+		table.headers = columnHeaders(rawWidth(data))
+	}
+	if len(data) > headerRows {
+		table.values = data[headerRows:]
+	}
+	table.schema = resolveColumnSchema(sheet.ColumnSchema, sheetRows(data, useHeader))
+	return table, nil
+}
+
+// batchRun is the outcome of applying a batch's operations to its tables
+type batchRun struct {
+	tables   map[string]*batchTable
+	results  []gin.H           // one per operation
+	requests []*sheets.Request // the changes, in order
+	units    int               // quota units charged
+	errors   []gin.H           // validation errors
+}
+
+// runBatch applies the operations to copies of the loaded tables, drawing
+// auto-increment values from seq (nil counts them within the batch)
+func runBatch(ops []batchOperation, collections []string, loaded map[string]*batchTable, sheet models.AllowedSheet, limits models.PlanLimits, seq func(collection string) idSequence) *batchRun {
+	run := &batchRun{tables: make(map[string]*batchTable, len(loaded)), results: make([]gin.H, 0, len(ops))}
+	for collection, table := range loaded {
+		run.tables[collection] = table.clone(sheet, seq(collection))
+	}
+
+	for i, op := range ops {
+		table := run.tables[collections[i]]
+
+		var result gin.H
+		var opRequests []*sheets.Request
+		var errs []gin.H
+		switch op.Op {
+		case "insert":
+			result, opRequests, errs = batchInsert(table, op, sheet, limits)
+			if n, ok := result["inserted"].(int); ok {
+				run.units += limits.GetBulkWriteUnits(n)
+			}
+		case "update":
+			result, opRequests, errs = batchUpdate(table, op, sheet)
+			run.units++
+		case "delete":
+			result, opRequests, errs = batchDelete(table, op, sheet)
+			run.units++
+		}
+		for _, e := range errs {
+			e["index"] = i
+			run.errors = append(run.errors, e)
+		}
+		if result != nil {
+			result["op"] = op.Op
+			result["collection"] = collections[i]
+			run.results = append(run.results, result)
+		}
+		run.requests = append(run.requests, opRequests...)
+	}
+	return run
+}
+
+// clone returns a copy of a freshly loaded table for one pass over the
+// operations, with a new generator drawing auto-increment values from seq
+func (t *batchTable) clone(sheet models.AllowedSheet, seq idSequence) *batchTable {
+	copied := *t
+	copied.headers = append([]interface{}{}, t.headers...)
+	copied.values = append([][]interface{}{}, t.values...)
+	copied.generator = newRowGenerator(sheet.ColumnSchema, t.rows(sheet.UseFirstRowAsHeader), seq)
+	return &copied
+}
+
+// rows returns the table's current rows as typed objects
+func (t *batchTable) rows(useHeader bool) []map[string]interface{} {
+	data := t.values
+	if useHeader {
+		data = append([][]interface{}{t.headers}, t.values...)
+	}
+	return coerceRows(sheetRows(data, useHeader), t.schema)
+}
+
+// object returns one row of values as a typed object
+func (t *batchTable) object(values []interface{}) map[string]interface{} {
+	return coerceRow(rowObject(t.headers, values), t.schema)
+}
+
+// widen grows a headerless table's columns to fit a row
+func (t *batchTable) widen(useHeader bool, width int) {
+	if !useHeader && width > len(t.headers) {
+		t.headers = columnHeaders(width)
+	}
+}
+
+// batchInsert validates the rows of an insert and appends them to the table
+func batchInsert(t *batchTable, op batchOperation, sheet models.AllowedSheet, limits models.PlanLimits) (gin.H, []*sheets.Request, []gin.H) {
+	inputs, _, err := parseWriteRows(op.Data)
+	if err != nil {
+		return nil, nil, []gin.H{{"details": err.Error()}}
+	}
+	if limits.MaxBulkRows > 0 && len(inputs) > limits.MaxBulkRows {
+		return nil, nil, []gin.H{{"details": fmt.Sprintf("your plan allows at most %d rows per bulk write", limits.MaxBulkRows)}}
+	}
+
+	useHeader := sheet.UseFirstRowAsHeader
+	mode := sheet.InputMode()
+	var rows [][]interface{}
+	var errs []gin.H
+	for r, input := range inputs {
+		if !useHeader {
+			if input, err = positionalData(input); err != nil {
+				errs = append(errs, batchRowError(r, err))
+				continue
+			}
+		}
+		row, err := mapWriteRow(t.generator.insert(input), t.headers, useHeader, sheet.ColumnSchema, sheet.StrictFields)
+		if err != nil {
+			errs = append(errs, batchRowError(r, err))
+			continue
+		}
+		t.widen(useHeader, len(row))
+		rows = append(rows, row)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+
+	rowData := make([]*sheets.RowData, len(rows))
+	data := make([]map[string]interface{}, len(rows))
+	for r, row := range rows {
+		cells := make([]*sheets.CellData, 0, len(row))
+		for _, v := range row {
+			cells = append(cells, cellData(v, mode))
+		}
+		rowData[r] = &sheets.RowData{Values: cells}
+		data[r] = t.object(row)
+	}
+	t.values = append(t.values, rows...)
+
+	request := &sheets.Request{AppendCells: &sheets.AppendCellsRequest{
+		SheetId: t.tabID,
+		Rows:    rowData,
+		Fields:  cellFields,
+	}}
+	return gin.H{"data": data, "inserted": len(rows)}, []*sheets.Request{request}, nil
+}
+
+// batchUpdate merges the data into every row matching where (up to limit),
+// writing only the cells that change
+func batchUpdate(t *batchTable, op batchOperation, sheet models.AllowedSheet) (gin.H, []*sheets.Request, []gin.H) {
+	useHeader := sheet.UseFirstRowAsHeader
+	if len(op.Where) == 0 {
+		return nil, nil, []gin.H{{"details": "where is required to select the rows to update"}}
+	}
+	filter, err := parseFilterObject(op.Where)
+	if err == nil && !useHeader {
+		err = positionalFilter(filter)
+	}
+	if err != nil {
+		return nil, nil, []gin.H{{"details": "invalid where filter: " + err.Error()}}
+	}
+	if op.Limit != nil && *op.Limit <= 0 {
+		return nil, nil, []gin.H{{"details": "limit must be a positive integer"}}
+	}
+
+	inputs, bulk, err := parseWriteRows(op.Data)
+	if err == nil && bulk {
+		err = fmt.Errorf("data must be an object")
+	}
+	if err != nil {
+		return nil, nil, []gin.H{{"details": err.Error()}}
+	}
+	input := inputs[0]
+	if !useHeader {
+		if input, err = positionalData(input); err != nil {
+			return nil, nil, []gin.H{batchFieldError(err)}
+		}
+	}
+
+	problems := &validationError{}
+	data, err := prepareWriteData(input, sheet.ColumnSchema)
+	if err != nil {
+		problems.merge(err)
+	}
+	if useHeader && sheet.StrictFields {
+		checkUnknownFields(problems, input, t.headers)
+	}
+	if err := problems.err(); err != nil {
+		return nil, nil, []gin.H{batchFieldError(err)}
+	}
+	t.widen(useHeader, len(mapRawRow(data, 0)))
+
+	mode := sheet.InputMode()
+	var requests []*sheets.Request
+	updatedRows := make([]map[string]interface{}, 0)
+	updated := 0
+	for i, row := range t.rows(useHeader) {
+		if op.Limit != nil && len(updatedRows) >= *op.Limit {
+			break
+		}
+		if !filter.matches(row) {
+			continue
+		}
+
+		prev := t.values[i]
+		newRow, changed := mergeRow(t.headers, prev, data, t.schema)
+		if changed {
+			newRow, _ = mergeRow(t.headers, prev, t.generator.update(data, row), t.schema)
+			requests = append(requests, cellUpdateRequests(t, i, prev, newRow, mode)...)
+			t.values[i] = newRow
+			updated++
+		}
+		updatedRows = append(updatedRows, t.object(newRow))
+	}
+
+	return gin.H{"data": updatedRows, "matched": len(updatedRows), "updated": updated}, requests, nil
+}
+
+// batchDelete removes every row matching where (up to limit)
+func batchDelete(t *batchTable, op batchOperation, sheet models.AllowedSheet) (gin.H, []*sheets.Request, []gin.H) {
+	if len(op.Where) == 0 {
+		return nil, nil, []gin.H{{"details": "where is required to select the rows to delete"}}
+	}
+	filter, err := parseFilterObject(op.Where)
+	if err == nil && !sheet.UseFirstRowAsHeader {
+		err = positionalFilter(filter)
+	}
+	if err != nil {
+		return nil, nil, []gin.H{{"details": "invalid where filter: " + err.Error()}}
+	}
+	if op.Limit != nil && *op.Limit <= 0 {
+		return nil, nil, []gin.H{{"details": "limit must be a positive integer"}}
+	}
+
+	var matched []int
+	for i, row := range t.rows(sheet.UseFirstRowAsHeader) {
+		if op.Limit != nil && len(matched) >= *op.Limit {
+			break
+		}
+		if filter.matches(row) {
+			matched = append(matched, i)
+		}
+	}
+
+	// Sheet rows are 1-based, DeleteDimension indexes 0-based
+	rowIndexes := make([]int64, len(matched))
+	for n, i := range matched {
+		rowIndexes[n] = int64(t.firstRow + i - 1)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(matched)))
+	for _, i := range matched {
+		t.values = append(t.values[:i], t.values[i+1:]...)
+	}
+
+	return gin.H{"deleted": len(rowIndexes)}, deleteRowRequests(t.tabID, rowIndexes), nil
+}
+
+// cellUpdateRequests writes each cell that differs between prev and next,
+// leaving the row's other cells (and their formulas and formats) untouched
+func cellUpdateRequests(t *batchTable, i int, prev, next []interface{}, mode string) []*sheets.Request {
+	rowIndex := int64(t.firstRow - 1 + i)
+	var requests []*sheets.Request
+	for j, v := range next {
+		var p interface{}
+		if j < len(prev) {
+			p = prev[j]
+		}
+		if sameCell(p, v, columnTypeAt(t.headers, t.schema, j)) {
+			continue
+		}
+		column := int64(j)
+		cell := cellData(v, mode)
+		fields := "userEnteredValue"
+		if cell.UserEnteredFormat != nil {
+			fields = cellFields
+		}
+		requests = append(requests, &sheets.Request{UpdateCells: &sheets.UpdateCellsRequest{
+			Range: &sheets.GridRange{
+				SheetId:          t.tabID,
+				StartRowIndex:    rowIndex,
+				EndRowIndex:      rowIndex + 1,
+				StartColumnIndex: column,
+				EndColumnIndex:   column + 1,
+			},
+			Rows:   []*sheets.RowData{{Values: []*sheets.CellData{cell}}},
+			Fields: fields,
+		}})
+	}
+	return requests
+}
+
+// cellFields are the cell fields batch writes set: the value, and the number
+// format that dates, currency and percentages are displayed with
+const cellFields = "userEnteredValue,userEnteredFormat.numberFormat"
+
+// userEnteredDateLayouts are the date formats USER_ENTERED input turns into
+// dates, with the number format Sheets gives them
+var userEnteredDateLayouts = []struct {
+	layout, pattern, kind string
+}{
+	{"2006-01-02", "yyyy-mm-dd", "DATE"},
+	{"1/2/2006", "m/d/yyyy", "DATE"},
+	{"2006-01-02 15:04:05", "yyyy-mm-dd hh:mm:ss", "DATE_TIME"},
+	{"2006-01-02 15:04", "yyyy-mm-dd hh:mm", "DATE_TIME"},
+	{"1/2/2006 15:04:05", "m/d/yyyy h:mm:ss", "DATE_TIME"},
+}
+
+// thousandsPattern matches numbers written with thousands separators
+var thousandsPattern = regexp.MustCompile(`^-?\d{1,3}(,\d{3})+(\.\d+)?$`)
+
+// sheetsEpoch is day 0 of Sheets date serial numbers
+var sheetsEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// cellData converts a value for Spreadsheets.BatchUpdate, which takes typed
+// cells rather than input to parse. Unless the sheet is RAW, strings are read
+// the way USER_ENTERED reads them: formulas (where the input mode allows
+// them), numbers, booleans, and dates, currency and percentages, which also
+// get a number format.
+func cellData(value interface{}, mode string) *sheets.CellData {
+	if isEmptyValue(value) {
+		return &sheets.CellData{}
+	}
+
+	cell := &sheets.CellData{UserEnteredValue: &sheets.ExtendedValue{}}
+	ev := cell.UserEnteredValue
+	switch v := value.(type) {
+	case bool:
+		ev.BoolValue = &v
+	case string:
+		switch {
+		case mode == models.InputModeRaw:
+			ev.StringValue = &v
+		case strings.HasPrefix(v, "'"):
+			s := v[1:]
+			ev.StringValue = &s
+		case strings.HasPrefix(v, "=") && mode == models.InputModeUserEntered:
+			ev.FormulaValue = &v
+		default:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				ev.NumberValue = &f
+			} else if b := strings.ToUpper(strings.TrimSpace(v)); b == "TRUE" || b == "FALSE" {
+				boolean := b == "TRUE"
+				ev.BoolValue = &boolean
+			} else if f, format, ok := userEnteredNumber(strings.TrimSpace(v)); ok {
+				ev.NumberValue = &f
+				cell.UserEnteredFormat = &sheets.CellFormat{NumberFormat: format}
+			} else {
+				ev.StringValue = &v
+			}
+		}
+	default:
+		if f, ok := models.NumericValue(v); ok {
+			ev.NumberValue = &f
+		} else {
+			s := fmt.Sprintf("%v", v)
+			ev.StringValue = &s
+		}
+	}
+	return cell
+}
+
+// userEnteredNumber parses the text USER_ENTERED stores as a formatted number:
+// dates and date-times (as serial numbers), currency amounts such as
+// "$1,200.50", percentages such as "12.5%" and numbers with thousands
+// separators
+func userEnteredNumber(s string) (float64, *sheets.NumberFormat, bool) {
+	for _, d := range userEnteredDateLayouts {
+		if t, err := time.Parse(d.layout, s); err == nil {
+			serial := t.Sub(sheetsEpoch).Hours() / 24
+			return serial, &sheets.NumberFormat{Type: d.kind, Pattern: d.pattern}, true
+		}
+	}
+
+	if number, ok := strings.CutSuffix(s, "%"); ok {
+		if f, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", ""), 64); err == nil {
+			return f / 100, &sheets.NumberFormat{Type: "PERCENT", Pattern: "0" + decimalPattern(number) + "%"}, true
+		}
+		return 0, nil, false
+	}
+
+	sign := ""
+	number := s
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	if amount, ok := strings.CutPrefix(number, "$"); ok {
+		if amount == "" || (!thousandsPattern.MatchString(amount) && strings.Trim(amount, "0123456789.") != "") {
+			return 0, nil, false
+		}
+		if f, err := strconv.ParseFloat(sign+strings.ReplaceAll(amount, ",", ""), 64); err == nil {
+			return f, &sheets.NumberFormat{Type: "CURRENCY", Pattern: "$#,##0" + decimalPattern(amount)}, true
+		}
+		return 0, nil, false
+	}
+
+	if thousandsPattern.MatchString(s) {
+		if f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64); err == nil {
+			return f, &sheets.NumberFormat{Type: "NUMBER", Pattern: "#,##0" + decimalPattern(s)}, true
+		}
+	}
+	return 0, nil, false
+}
+
+// decimalPattern returns the fraction part of a number format showing as
+// many decimals as the number was written with (".00" for "1.50")
+func decimalPattern(number string) string {
+	_, decimals, ok := strings.Cut(number, ".")
+	if !ok || decimals == "" {
+		return ""
+	}
+	return "." + strings.Repeat("0", len(decimals))
+}
+
+// batchRowError describes a failed row of an insert operation
+func batchRowError(row int, err error) gin.H {
+	e := batchFieldError(err)
+	e["row"] = row
+	return e
+}
+
+// batchFieldError describes an operation whose data failed validation
+func batchFieldError(err error) gin.H {
+	e := gin.H{"details": err.Error()}
+	if fields := fieldErrors(err); fields != nil {
+		e["fields"] = fields
+	}
+	return e
+}
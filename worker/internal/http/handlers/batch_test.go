@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gsheetbase/shared/models"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// newTestBatchTable returns a headed table of name/qty rows starting at sheet row 2
+func newTestBatchTable(names ...string) *batchTable {
+	t := &batchTable{
+		tabID:     7,
+		headers:   []interface{}{"name", "qty"},
+		firstRow:  2,
+		generator: newRowGenerator(nil, nil, nil),
+	}
+	for _, name := range names {
+		t.values = append(t.values, []interface{}{name, "1"})
+	}
+	return t
+}
+
+func TestCellUpdateRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		firstRow   int
+		i          int
+		prev, next []interface{}
+		want       [][2]int64 // row and column index of each written cell
+	}{
+		{"unchanged row", 2, 0, []interface{}{"a", "1"}, []interface{}{"a", "1"}, nil},
+		{"one changed cell", 2, 0, []interface{}{"a", "1"}, []interface{}{"a", "2"}, [][2]int64{{1, 1}}},
+		{"later row", 2, 3, []interface{}{"a", "1"}, []interface{}{"b", "1"}, [][2]int64{{4, 0}}},
+		{"headerless sheet", 1, 1, []interface{}{"a"}, []interface{}{"b", "x"}, [][2]int64{{1, 0}, {1, 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &batchTable{tabID: 7, firstRow: tt.firstRow}
+			got := updatedCells(t, cellUpdateRequests(table, tt.i, tt.prev, tt.next, models.InputModeUserEntered))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cells written = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchRowIndexesAfterDelete(t *testing.T) {
+	sheet := models.AllowedSheet{UseFirstRowAsHeader: true}
+	update := json.RawMessage(`{"qty":"9"}`)
+
+	tests := []struct {
+		name        string
+		rows        []string
+		deleteWhere map[string]interface{}
+		updateWhere map[string]interface{}
+		wantDeletes []int64
+		wantUpdates [][2]int64
+	}{
+		{
+			name:        "row after a deleted row moves up",
+			rows:        []string{"a", "b", "c", "d"},
+			deleteWhere: map[string]interface{}{"name": "b"},
+			updateWhere: map[string]interface{}{"name": "d"},
+			wantDeletes: []int64{2},
+			wantUpdates: [][2]int64{{3, 1}},
+		},
+		{
+			name:        "row before a deleted row stays",
+			rows:        []string{"a", "b", "c", "d"},
+			deleteWhere: map[string]interface{}{"name": "c"},
+			updateWhere: map[string]interface{}{"name": "a"},
+			wantDeletes: []int64{3},
+			wantUpdates: [][2]int64{{1, 1}},
+		},
+		{
+			name:        "several deleted rows",
+			rows:        []string{"a", "b", "c", "d", "e"},
+			deleteWhere: map[string]interface{}{"name": map[string]interface{}{"$in": []interface{}{"a", "c"}}},
+			updateWhere: map[string]interface{}{"name": map[string]interface{}{"$in": []interface{}{"b", "e"}}},
+			wantDeletes: []int64{3, 1},
+			wantUpdates: [][2]int64{{1, 1}, {3, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := newTestBatchTable(tt.rows...)
+
+			_, requests, errs := batchDelete(table, batchOperation{Op: "delete", Where: tt.deleteWhere}, sheet)
+			if errs != nil {
+				t.Fatalf("batchDelete errors = %v", errs)
+			}
+			var deletes []int64
+			for _, r := range requests {
+				deletes = append(deletes, r.DeleteDimension.Range.StartIndex)
+			}
+			if !reflect.DeepEqual(deletes, tt.wantDeletes) {
+				t.Errorf("deleted row indexes = %v, want %v", deletes, tt.wantDeletes)
+			}
+
+			result, requests, errs := batchUpdate(table, batchOperation{Op: "update", Where: tt.updateWhere, Data: update}, sheet)
+			if errs != nil {
+				t.Fatalf("batchUpdate errors = %v", errs)
+			}
+			if got := updatedCells(t, requests); !reflect.DeepEqual(got, tt.wantUpdates) {
+				t.Errorf("updated cells = %v, want %v", got, tt.wantUpdates)
+			}
+			if result["updated"] != len(tt.wantUpdates) {
+				t.Errorf("updated = %v, want %d", result["updated"], len(tt.wantUpdates))
+			}
+		})
+	}
+}
+
+func TestCellData(t *testing.T) {
+	str := func(s string) *sheets.ExtendedValue { return &sheets.ExtendedValue{StringValue: &s} }
+	num := func(f float64) *sheets.ExtendedValue { return &sheets.ExtendedValue{NumberValue: &f} }
+	boolean := func(b bool) *sheets.ExtendedValue { return &sheets.ExtendedValue{BoolValue: &b} }
+	formula := func(s string) *sheets.ExtendedValue { return &sheets.ExtendedValue{FormulaValue: &s} }
+
+	tests := []struct {
+		name  string
+		value interface{}
+		mode  string
+		want  *sheets.ExtendedValue
+	}{
+		{"empty", "", models.InputModeUserEntered, nil},
+		{"number string", "42", models.InputModeUserEntered, num(42)},
+		{"boolean string", "true", models.InputModeUserEntered, boolean(true)},
+		{"formula", "=A1", models.InputModeUserEntered, formula("=A1")},
+		{"escaped formula", "'=A1", models.InputModeSanitized, str("=A1")},
+		{"formula as text when sanitized", "=A1", models.InputModeSanitized, str("=A1")},
+		{"raw keeps text", "42", models.InputModeRaw, str("42")},
+		{"float", float64(1.5), models.InputModeRaw, num(1.5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cellData(tt.value, tt.mode).UserEnteredValue; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cellData(%v, %s) = %+v, want %+v", tt.value, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserEnteredNumber(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    float64
+		kind    string
+		pattern string
+	}{
+		{"2025-01-02", 45659, "DATE", "yyyy-mm-dd"},
+		{"1/2/2025", 45659, "DATE", "m/d/yyyy"},
+		{"2025-01-02 18:00", 45659.75, "DATE_TIME", "yyyy-mm-dd hh:mm"},
+		{"$1,200.50", 1200.5, "CURRENCY", "$#,##0.00"},
+		{"-$5", -5, "CURRENCY", "$#,##0"},
+		{"12.5%", 0.125, "PERCENT", "0.0%"},
+		{"1,000", 1000, "NUMBER", "#,##0"},
+		{"hello", 0, "", ""},
+		{"$abc", 0, "", ""},
+		{"1,00", 0, "", ""},
+		{"2025-13-01", 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, format, ok := userEnteredNumber(tt.text)
+			if ok != (tt.kind != "") {
+				t.Fatalf("userEnteredNumber(%q) ok = %v", tt.text, ok)
+			}
+			if !ok {
+				return
+			}
+			if got != tt.want || format.Type != tt.kind || format.Pattern != tt.pattern {
+				t.Errorf("userEnteredNumber(%q) = %v %s %q, want %v %s %q", tt.text, got, format.Type, format.Pattern, tt.want, tt.kind, tt.pattern)
+			}
+		})
+	}
+}
+
+func TestRunBatchLeavesLoadedTables(t *testing.T) {
+	sheet := models.AllowedSheet{
+		UseFirstRowAsHeader: true,
+		ColumnSchema:        models.ColumnSchema{"id": {Type: models.ColumnInteger, Generate: models.GenerateAutoIncrement}},
+	}
+	loaded := map[string]*batchTable{"Orders": {
+		tabID:    7,
+		headers:  []interface{}{"id", "name"},
+		values:   [][]interface{}{{"1", "a"}, {"2", "b"}},
+		firstRow: 2,
+	}}
+	ops := []batchOperation{
+		{Op: "delete", Where: map[string]interface{}{"name": "a"}},
+		{Op: "insert", Data: json.RawMessage(`{"name":"c"}`)},
+	}
+	collections := []string{"Orders", "Orders"}
+
+	var reserved []int
+	seq := func(string) idSequence {
+		return func(column string, floor int64, n int) (int64, error) {
+			reserved = append(reserved, n)
+			return floor, nil
+		}
+	}
+	noSeq := func(string) idSequence { return nil }
+
+	check := runBatch(ops, collections, loaded, sheet, models.PlanLimits{}, noSeq)
+	if len(check.errors) > 0 {
+		t.Fatalf("errors = %v", check.errors)
+	}
+	if len(reserved) > 0 {
+		t.Fatal("validation pass reserved auto_increment values")
+	}
+	if len(loaded["Orders"].values) != 2 {
+		t.Fatalf("validation pass changed the loaded table: %v", loaded["Orders"].values)
+	}
+
+	run := runBatch(ops, collections, loaded, sheet, models.PlanLimits{}, seq)
+	if !reflect.DeepEqual(check.results, run.results) || len(run.requests) != 2 {
+		t.Errorf("second pass = %v, want %v", run.results, check.results)
+	}
+	if !reflect.DeepEqual(reserved, []int{1}) {
+		t.Errorf("reserved blocks = %v, want [1]", reserved)
+	}
+}
+
+// updatedCells returns the row and column index of each single-cell UpdateCells request
+func updatedCells(t *testing.T, requests []*sheets.Request) [][2]int64 {
+	t.Helper()
+	var cells [][2]int64
+	for _, r := range requests {
+		if r.UpdateCells == nil {
+			t.Fatalf("unexpected request %+v", r)
+		}
+		g := r.UpdateCells.Range
+		if g.SheetId != 7 || g.EndRowIndex != g.StartRowIndex+1 || g.EndColumnIndex != g.StartColumnIndex+1 {
+			t.Fatalf("range %+v is not a single cell of tab 7", g)
+		}
+		cells = append(cells, [2]int64{g.StartRowIndex, g.StartColumnIndex})
+	}
+	return cells
+}
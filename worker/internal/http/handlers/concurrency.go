@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rowVersionKey holds a row's version: a hash of its cells as stored in the
+// sheet. Clients send it back in If-Match to make writes conditional.
+const rowVersionKey = "_version"
+
+// rowVersion hashes a sheet row's cells. Trailing empty cells are ignored so
+// the version doesn't depend on how far the Sheets API pads a row.
+func rowVersion(values []interface{}) string {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		if v != nil {
+			cells[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	for len(cells) > 0 && cells[len(cells)-1] == "" {
+		cells = cells[:len(cells)-1]
+	}
+	b, _ := json.Marshal(cells)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// attachRowVersions records the version of each data row
+func attachRowVersions(rows []map[string]interface{}, data [][]interface{}, headerRows int) {
+	for i, row := range rows {
+		row[rowVersionKey] = rowVersion(data[i+headerRows])
+	}
+}
+
+// ifMatch is a parsed If-Match header
+type ifMatch struct {
+	present  bool
+	any      bool // "*": the row only has to exist
+	versions map[string]bool
+}
+
+// parseIfMatch reads the If-Match header. Weak tags never match, since
+// If-Match uses strong comparison.
+func parseIfMatch(c *gin.Context) ifMatch {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	m := ifMatch{present: header != "", versions: make(map[string]bool)}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "*":
+			m.any = true
+		case strings.HasPrefix(tag, "W/"):
+			continue
+		case tag != "":
+			m.versions[strings.Trim(tag, `"`)] = true
+		}
+	}
+	return m
+}
+
+// allows reports whether an existing row with this version may be written
+func (m ifMatch) allows(version string) bool {
+	return !m.present || m.any || m.versions[version]
+}
+
+// preconditionFailed answers 412 with the rows whose versions no longer match
+func preconditionFailed(c *gin.Context, conflicts []gin.H) {
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":     "precondition failed",
+		"details":   "the row changed since it was read; fetch it again and retry",
+		"conflicts": conflicts,
+	})
+}
+
+// versionedRow shapes written values for a response: coerced, limited to the
+// returning fields, and tagged with the row's new version
+func versionedRow(headers, values []interface{}, schema models.ColumnSchema, returning []string) map[string]interface{} {
+	row := pickFields(coerceRow(rowObject(headers, values), schema), returning)
+	row[rowVersionKey] = rowVersion(values)
+	return row
+}
+
+// setRowVersion reports the _version of a response's only row in the
+// X-Row-Version header, ready for If-Match. Responses holding several rows
+// only carry each row's _version in the body.
+func setRowVersion(c *gin.Context, rows []map[string]interface{}) {
+	if len(rows) != 1 {
+		return
+	}
+	if version, ok := rows[0][rowVersionKey].(string); ok {
+		c.Header("X-Row-Version", `"`+version+`"`)
+	}
+}
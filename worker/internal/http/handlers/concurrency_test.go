@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRowVersion(t *testing.T) {
+	base := rowVersion([]interface{}{"a", "1"})
+
+	tests := []struct {
+		name   string
+		values []interface{}
+		same   bool
+	}{
+		{"trailing empty cells", []interface{}{"a", "1", "", nil}, true},
+		{"number and text", []interface{}{"a", float64(1)}, true},
+		{"changed cell", []interface{}{"a", "2"}, false},
+		{"shifted cells", []interface{}{"", "a", "1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rowVersion(tt.values) == base; got != tt.same {
+				t.Errorf("rowVersion(%v) equal to base = %v, want %v", tt.values, got, tt.same)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version string
+		want    bool
+	}{
+		{"no header", "", "abc", true},
+		{"listed version", `"abc", "def"`, "def", true},
+		{"other version", `"abc"`, "def", false},
+		{"any", "*", "def", true},
+		{"weak tag", `W/"abc"`, "abc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPut, "/v1/key", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("If-Match", tt.header)
+			}
+			if got := parseIfMatch(c).allows(tt.version); got != tt.want {
+				t.Errorf("If-Match %q allows %q = %v, want %v", tt.header, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetRowVersion(t *testing.T) {
+	values := []interface{}{"7", "shipped"}
+	row := versionedRow([]interface{}{"id", "status"}, values, nil, []string{"status"})
+	if row[rowVersionKey] != rowVersion(values) {
+		t.Fatalf("versionedRow _version = %v, want %s", row[rowVersionKey], rowVersion(values))
+	}
+
+	tests := []struct {
+		name string
+		rows []map[string]interface{}
+		want string
+	}{
+		{"one row", []map[string]interface{}{row}, `"` + rowVersion(values) + `"`},
+		{"several rows", []map[string]interface{}{row, row}, ""},
+		{"no rows", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			setRowVersion(c, tt.rows)
+			if got := w.Header().Get("X-Row-Version"); got != tt.want {
+				t.Errorf("X-Row-Version = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
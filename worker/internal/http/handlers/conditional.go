@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/worker/internal/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeConditionalJSON serializes the payload and writes it with
+// writeConditional
+func writeConditionalJSON(c *gin.Context, payload interface{}, fetchedAt time.Time, limits models.PlanLimits) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response", "details": err.Error()})
+		return
+	}
+	writeConditional(c, "application/json; charset=utf-8", body, fetchedAt, limits)
+}
+
+// writeConditional tags a response body with an ETag and with Last-Modified
+// set to when its data was read from the sheet, and answers 304 Not Modified
+// when the client already holds this representation. A 304 is charged at the
+// plan's NotModifiedWeight.
+func writeConditional(c *gin.Context, contentType string, body []byte, fetchedAt time.Time, limits models.PlanLimits) {
+	etag := cache.ComputeETag(body)
+	lastModified := fetchedAt.UTC().Format(http.TimeFormat)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified)
+
+	if cache.NotModified(c.Request.Header, etag, lastModified) {
+		c.Set("usage_weight", limits.NotModifiedWeight)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
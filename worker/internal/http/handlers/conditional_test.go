@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteConditionalJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fetchedAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	limits := models.PlanLimits{NotModifiedWeight: 25}
+	payload := gin.H{"data": []interface{}{gin.H{"name": "a"}}}
+
+	// The ETag the response carries, for the If-None-Match cases
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/key", nil)
+	writeConditionalJSON(c, payload, fetchedAt, limits)
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") != "Fri, 16 Oct 2026 12:00:00 GMT" {
+		t.Fatalf("ETag = %q, Last-Modified = %q", etag, w.Header().Get("Last-Modified"))
+	}
+
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		wantStatus      int
+	}{
+		{"unconditional", "", "", http.StatusOK},
+		{"matching etag", etag, "", http.StatusNotModified},
+		{"stale etag", `"stale"`, "", http.StatusOK},
+		{"not modified since", "", "Fri, 16 Oct 2026 12:00:00 GMT", http.StatusNotModified},
+		{"modified since", "", "Fri, 16 Oct 2026 11:00:00 GMT", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/key", nil)
+			if tt.ifNoneMatch != "" {
+				c.Request.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModifiedSince != "" {
+				c.Request.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+			writeConditionalJSON(c, payload, fetchedAt, limits)
+			c.Writer.WriteHeaderNow()
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), etag)
+			}
+			weight, charged := c.Get("usage_weight")
+			if tt.wantStatus == http.StatusNotModified {
+				if weight != limits.NotModifiedWeight || w.Body.Len() != 0 {
+					t.Errorf("304 charged %v with a %d byte body, want %d and none", weight, w.Body.Len(), limits.NotModifiedWeight)
+				}
+			} else if charged {
+				t.Errorf("200 set usage_weight %v", weight)
+			}
+		})
+	}
+}
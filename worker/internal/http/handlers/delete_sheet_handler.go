@@ -1,28 +1,47 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
+	"strconv"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/worker/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-// DeletePublic handles DELETE /v1/:api_key?collection=assets&where={...}
+// DeletePublic handles DELETE /v1/:api_key?collection=assets&where={...}&limit=10&dry_run=1
+// Every row matching all where conditions is deleted (up to limit, in sheet order).
+// dry_run=1 returns the rows that would be deleted without removing them.
 func (h *SheetHandler) DeletePublic(c *gin.Context) {
-	apiKey := c.Param("api_key")
-	if apiKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "api_key is required"})
-		return
-	}
-
 	// Parse query params
 	collection := c.Query("collection")
 	where := c.Query("where")
+	dryRun := c.Query("dry_run") == "1"
 
-	// Find the sheet by API key
-	sheet, err := h.sheetRepo.FindByAPIKey(c.Request.Context(), apiKey)
+	// Filter rows to delete
+	filter, err := parseFilter(where)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "invalid api key or sheet not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
+		return
+	}
+	if filter == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "where is required to select the rows to delete"})
+		return
+	}
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+	}
+
+	// The sheet was resolved by SheetAuthMiddleware (api_key or Authorization header)
+	sheet, ok := middleware.SheetFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet not resolved"})
 		return
 	}
 
@@ -44,6 +63,15 @@ func (h *SheetHandler) DeletePublic(c *gin.Context) {
 		return
 	}
 
+	// Headerless sheets key the filter by column letter or index
+	useHeader := sheet.UseFirstRowAsHeader
+	if !useHeader {
+		if err := positionalFilter(filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
+			return
+		}
+	}
+
 	// Determine range (use collection as sheet name, fallback to default)
 	targetRange := collection
 	if targetRange == "" && sheet.DefaultRange != nil {
@@ -52,48 +80,69 @@ func (h *SheetHandler) DeletePublic(c *gin.Context) {
 	if targetRange == "" {
 		targetRange = "Sheet1"
 	}
+	if !requireScope(c, models.ScopeDelete, targetRange) {
+		return
+	}
 
 	// Fetch current sheet data
 	sheetData, err := h.fetchSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange)
-	if err != nil || len(sheetData) == 0 {
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet data", "details": err.Error()})
 		return
 	}
-	rows := transformToJSON(sheetData)
-
-	// Filter rows to delete
-	var cond map[string]interface{}
-	if where != "" {
-		if err := json.Unmarshal([]byte(where), &cond); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
-			return
-		}
+	if len(sheetData) == 0 {
+		// An empty tab has no rows to match
+		c.JSON(http.StatusNotFound, gin.H{"error": "no rows matched to delete"})
+		return
 	}
+	rows := sheetRows(sheetData, useHeader)
+	schema := resolveColumnSchema(sheet.ColumnSchema, rows)
+	rows = coerceRows(rows, schema)
+	attachRowVersions(rows, sheetData, headerRowCount(useHeader))
+	firstRow := rangeStartRow(targetRange) + headerRowCount(useHeader)
 
-	match := false
-	rowIndexToDelete := -1
+	// With If-Match every matched row must still carry one of the given versions
+	precondition := parseIfMatch(c)
+	var conflicts []gin.H
+	var matchedRows []map[string]interface{}
+	var rowIndexes []int64
 	for i, row := range rows {
-		if where != "" {
-			for k, v := range cond {
-				if row[k] == v {
-					match = true
-					rowIndexToDelete = i + 1 // header row additional
-					break
-				}
+		if limit > 0 && len(rowIndexes) >= limit {
+			break
+		}
+		if filter.matches(row) {
+			if version := row[rowVersionKey].(string); !precondition.allows(version) {
+				conflicts = append(conflicts, gin.H{"row": firstRow + i, "version": version})
 			}
+			matchedRows = append(matchedRows, row)
+			// Sheet rows are 1-based, DeleteDimension indexes 0-based
+			rowIndexes = append(rowIndexes, int64(firstRow+i-1))
 		}
 	}
 
-	if !match {
+	if len(rowIndexes) == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "no rows matched to delete"})
 		return
 	}
+	if len(conflicts) > 0 {
+		preconditionFailed(c, conflicts)
+		return
+	}
+
+	if dryRun {
+		columns := exportColumns(sheetData, "", useHeader)
+		c.JSON(http.StatusOK, gin.H{
+			"data":    responseData(matchedRows, columns, useHeader),
+			"matched": len(matchedRows),
+			"dry_run": true,
+		})
+		return
+	}
 
-	// Write updated data back to sheet
-	if err := h.deleteSheetDataAtRowIndex(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange, int64(rowIndexToDelete)); err != nil {
+	if err := h.deleteSheetRows(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange, rowIndexes); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update data", "details": err.Error()})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	c.JSON(http.StatusOK, gin.H{"deleted": len(rowIndexes)})
 }
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response formats supported by GET
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+	formatXLSX   = "xlsx"
+)
+
+// formatContentTypes maps each export format to its media type
+var formatContentTypes = map[string]string{
+	formatJSON:   "application/json",
+	formatCSV:    "text/csv",
+	formatNDJSON: "application/x-ndjson",
+	formatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// acceptAliases maps additional Accept media types to a format
+var acceptAliases = map[string]string{
+	"application/ndjson":   formatNDJSON,
+	"application/jsonl":    formatNDJSON,
+	"application/json-seq": formatNDJSON,
+}
+
+// unsafeFilenameChars matches characters not allowed in a download filename
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// negotiateFormat picks the response format from ?format= or the Accept header.
+// The query parameter wins; an unsupported value is an error, while an Accept
+// header with no supported type falls back to JSON.
+func negotiateFormat(c *gin.Context) (string, error) {
+	if format := strings.ToLower(strings.TrimSpace(c.Query("format"))); format != "" {
+		if _, ok := formatContentTypes[format]; !ok {
+			return "", fmt.Errorf("unsupported format %q (use json, csv, ndjson or xlsx)", format)
+		}
+		return format, nil
+	}
+
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		for format, contentType := range formatContentTypes {
+			if mediaType == contentType {
+				return format, nil
+			}
+		}
+		if format, ok := acceptAliases[mediaType]; ok {
+			return format, nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// exportColumns returns the column order for tabular output: the requested
+// fields, otherwise the sheet headers (or column letters for a headerless
+// sheet) in sheet order
+func exportColumns(data [][]interface{}, fields string, useHeader bool) []string {
+	if fields != "" {
+		columns := []string{}
+		for _, f := range strings.Split(fields, ",") {
+			columns = append(columns, strings.TrimSpace(f))
+		}
+		return columns
+	}
+	if !useHeader {
+		return rawColumns(rawWidth(data))
+	}
+	if len(data) == 0 {
+		return []string{}
+	}
+	columns := make([]string, 0, len(data[0]))
+	for _, h := range data[0] {
+		columns = append(columns, fmt.Sprintf("%v", h))
+	}
+	return columns
+}
+
+// writeExport writes rows in a non-JSON format, with the same ETag and
+// Last-Modified handling as JSON reads (see writeConditional). Positional
+// (headerless) sheets are written without a header line and as arrays in
+// NDJSON. With download set, the response is marked as an attachment named
+// after the collection.
+func writeExport(c *gin.Context, format string, columns []string, rows []map[string]interface{}, positional, download bool, name string, fetchedAt time.Time, limits models.PlanLimits) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case formatCSV:
+		err = writeCSV(&buf, columns, rows, !positional)
+	case formatNDJSON:
+		err = writeNDJSON(&buf, columns, rows, positional)
+	case formatXLSX:
+		err = writeXLSX(&buf, name, columns, rows, !positional)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response", "details": err.Error()})
+		return
+	}
+
+	if download {
+		filename := unsafeFilenameChars.ReplaceAllString(name, "_")
+		if filename == "" || filename == "_" {
+			filename = "data"
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+	}
+	writeConditional(c, formatContentTypes[format]+contentTypeCharset(format), buf.Bytes(), fetchedAt, limits)
+}
+
+// contentTypeCharset returns the charset suffix for text formats
+func contentTypeCharset(format string) string {
+	if format == formatXLSX {
+		return ""
+	}
+	return "; charset=utf-8"
+}
+
+// exportCell renders a value as text for CSV and XLSX cells
+func exportCell(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			items = append(items, exportCell(item))
+		}
+		return strings.Join(items, ", ")
+	case map[string]interface{}:
+		b, _ := json.Marshal(val)
+		return string(b)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// writeCSV writes an optional header line followed by one line per row
+func writeCSV(w io.Writer, columns []string, rows []map[string]interface{}, header bool) error {
+	cw := csv.NewWriter(w)
+	if header {
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, col := range columns {
+			record[i] = exportCell(row[col])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeNDJSON writes one JSON object per line, or one array in column order
+// when positional is set
+func writeNDJSON(w io.Writer, columns []string, rows []map[string]interface{}, positional bool) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, row := range rows {
+		var err error
+		if positional {
+			err = enc.Encode(rowsToArrays([]map[string]interface{}{row}, columns)[0])
+		} else {
+			err = enc.Encode(row)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeXLSX writes a minimal single-sheet Office Open XML workbook.
+// Strings are stored inline, so no shared-strings or styles parts are needed.
+func writeXLSX(w io.Writer, sheetName string, columns []string, rows []map[string]interface{}, header bool) error {
+	zw := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + xlsxSheetName(sheetName) + `" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+	for _, part := range parts {
+		fw, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, part.content); err != nil {
+			return err
+		}
+	}
+
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	sw := bufio.NewWriter(fw)
+	sw.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	sw.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	rowNum := 1
+	if header {
+		names := make([]interface{}, len(columns))
+		for i, col := range columns {
+			names[i] = col
+		}
+		writeXLSXRow(sw, rowNum, names)
+		rowNum++
+	}
+
+	values := make([]interface{}, len(columns))
+	for _, row := range rows {
+		for i, col := range columns {
+			values[i] = row[col]
+		}
+		writeXLSXRow(sw, rowNum, values)
+		rowNum++
+	}
+
+	sw.WriteString(`</sheetData></worksheet>`)
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeXLSXRow writes one <row>; numbers and booleans keep their cell types
+func writeXLSXRow(w *bufio.Writer, rowNum int, values []interface{}) {
+	fmt.Fprintf(w, `<row r="%d">`, rowNum)
+	for i, v := range values {
+		ref := columnLetter(i) + strconv.Itoa(rowNum)
+		switch val := v.(type) {
+		case nil:
+			continue
+		case float64:
+			fmt.Fprintf(w, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(val, 'f', -1, 64))
+		case int64:
+			fmt.Fprintf(w, `<c r="%s"><v>%d</v></c>`, ref, val)
+		case int:
+			fmt.Fprintf(w, `<c r="%s"><v>%d</v></c>`, ref, val)
+		case bool:
+			b := 0
+			if val {
+				b = 1
+			}
+			fmt.Fprintf(w, `<c r="%s" t="b"><v>%d</v></c>`, ref, b)
+		default:
+			fmt.Fprintf(w, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(w, []byte(exportCell(val)))
+			w.WriteString(`</t></is></c>`)
+		}
+	}
+	w.WriteString(`</row>`)
+}
+
+// xlsxSheetName makes a worksheet name valid: at most 31 characters and none of []:*?/\
+func xlsxSheetName(name string) string {
+	if i := strings.Index(name, "!"); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet1"
+	}
+	if len([]rune(name)) > 31 {
+		name = string([]rune(name)[:31])
+	}
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(name))
+	return strings.ReplaceAll(b.String(), `"`, "&quot;")
+}
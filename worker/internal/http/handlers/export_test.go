@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		accept  string
+		want    string
+		wantErr bool
+	}{
+		{"default", "", "", formatJSON, false},
+		{"query", "?format=CSV", "", formatCSV, false},
+		{"query wins over accept", "?format=json", "text/csv", formatJSON, false},
+		{"accept", "", "text/html, text/csv;q=0.9", formatCSV, false},
+		{"accept alias", "", "application/jsonl", formatNDJSON, false},
+		{"unsupported accept", "", "text/html", formatJSON, false},
+		{"unsupported query", "?format=pdf", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/key"+tt.query, nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+			got, err := negotiateFormat(c)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("negotiateFormat = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fetchedAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	columns := []string{"name", "qty"}
+	rows := []map[string]interface{}{{"name": "a, b", "qty": float64(2)}}
+
+	export := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v1/key?format=csv", nil)
+		if ifNoneMatch != "" {
+			c.Request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		writeExport(c, formatCSV, columns, rows, false, true, "Open Orders", fetchedAt, models.PlanLimits{})
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	w := export("")
+	if w.Code != http.StatusOK || w.Body.String() != "name,qty\n\"a, b\",2\n" {
+		t.Fatalf("status %d, body %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="Open_Orders.csv"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatal("export has no ETag or Last-Modified")
+	}
+
+	if w := export(etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("repeat with If-None-Match: status %d, %d byte body; want 304 and none", w.Code, w.Body.Len())
+	}
+}
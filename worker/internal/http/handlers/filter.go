@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gsheetbase/shared/models"
+)
+
+// filterNode is a parsed `where` expression. A node is either a logical group
+// ($and / $or over child nodes) or a single condition on one field.
+type filterNode struct {
+	and []*filterNode
+	or  []*filterNode
+
+	field string
+	op    string
+	value interface{}
+	re    *regexp.Regexp
+}
+
+// supportedOperators lists the field operators accepted in a where filter
+var supportedOperators = map[string]bool{
+	"$eq":         true,
+	"$ne":         true,
+	"$gt":         true,
+	"$gte":        true,
+	"$lt":         true,
+	"$lte":        true,
+	"$in":         true,
+	"$nin":        true,
+	"$contains":   true,
+	"$startsWith": true,
+	"$regex":      true,
+	"$exists":     true,
+}
+
+// parseFilter parses a where JSON string such as
+// {"price":{"$gte":10},"$or":[{"status":"active"},{"featured":true}]}
+// An empty string yields a nil filter that matches every row.
+func parseFilter(where string) (*filterNode, error) {
+	if strings.TrimSpace(where) == "" {
+		return nil, nil
+	}
+
+	var cond map[string]interface{}
+	if err := json.Unmarshal([]byte(where), &cond); err != nil {
+		return nil, fmt.Errorf("where must be a JSON object: %v", err)
+	}
+
+	return parseFilterObject(cond)
+}
+
+// parseFilterObject parses one JSON object; all of its keys are combined with AND
+func parseFilterObject(cond map[string]interface{}) (*filterNode, error) {
+	node := &filterNode{}
+
+	// Sort keys so error messages are deterministic
+	keys := make([]string, 0, len(cond))
+	for k := range cond {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := cond[key]
+
+		switch key {
+		case "$and", "$or":
+			children, err := parseFilterList(key, value)
+			if err != nil {
+				return nil, err
+			}
+			if key == "$and" {
+				node.and = append(node.and, &filterNode{and: children})
+			} else {
+				node.and = append(node.and, &filterNode{or: children})
+			}
+			continue
+		}
+
+		if strings.HasPrefix(key, "$") {
+			return nil, fmt.Errorf("unknown logical operator %q (use $and or $or)", key)
+		}
+
+		conditions, err := parseFieldCondition(key, value)
+		if err != nil {
+			return nil, err
+		}
+		node.and = append(node.and, conditions...)
+	}
+
+	return node, nil
+}
+
+// parseFilterList parses the array operand of $and / $or
+func parseFilterList(op string, value interface{}) ([]*filterNode, error) {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("%s expects a non-empty array of conditions", op)
+	}
+
+	children := make([]*filterNode, 0, len(list))
+	for i, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s[%d] must be an object", op, i)
+		}
+		child, err := parseFilterObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	return children, nil
+}
+
+// parseFieldCondition parses the value of a field key. A plain value means
+// equality; an object whose keys are all operators is a set of comparisons.
+func parseFieldCondition(field string, value interface{}) ([]*filterNode, error) {
+	ops, ok := value.(map[string]interface{})
+	if ok && hasOperator(ops) && !isOperatorObject(ops) {
+		return nil, fmt.Errorf("field %q mixes operators with plain keys; use only $ operators", field)
+	}
+	if !ok || !isOperatorObject(ops) {
+		return []*filterNode{{field: field, op: "$eq", value: value}}, nil
+	}
+
+	opNames := make([]string, 0, len(ops))
+	for op := range ops {
+		opNames = append(opNames, op)
+	}
+	sort.Strings(opNames)
+
+	nodes := make([]*filterNode, 0, len(ops))
+	for _, op := range opNames {
+		operand := ops[op]
+		if !supportedOperators[op] {
+			return nil, fmt.Errorf("unsupported operator %q on field %q", op, field)
+		}
+
+		node := &filterNode{field: field, op: op, value: operand}
+		switch op {
+		case "$in", "$nin":
+			if _, ok := operand.([]interface{}); !ok {
+				return nil, fmt.Errorf("%s on field %q expects an array", op, field)
+			}
+		case "$contains", "$startsWith":
+			if _, ok := operand.(string); !ok {
+				return nil, fmt.Errorf("%s on field %q expects a string", op, field)
+			}
+		case "$regex":
+			pattern, ok := operand.(string)
+			if !ok {
+				return nil, fmt.Errorf("$regex on field %q expects a string", field)
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid $regex on field %q: %v", field, err)
+			}
+			node.re = re
+		case "$exists":
+			if _, ok := operand.(bool); !ok {
+				return nil, fmt.Errorf("$exists on field %q expects true or false", field)
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			if operand == nil {
+				return nil, fmt.Errorf("%s on field %q cannot compare with null", op, field)
+			}
+			if _, ok := operand.(map[string]interface{}); ok {
+				return nil, fmt.Errorf("%s on field %q expects a number, string or date", op, field)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// isOperatorObject reports whether every key of the object is an operator
+func isOperatorObject(obj map[string]interface{}) bool {
+	if len(obj) == 0 {
+		return false
+	}
+	for k := range obj {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// hasOperator reports whether any key of the object is an operator
+func hasOperator(obj map[string]interface{}) bool {
+	for k := range obj {
+		if strings.HasPrefix(k, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// matches evaluates the filter against a single row. A nil filter matches everything.
+func (f *filterNode) matches(row map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.field == "" {
+		for _, child := range f.and {
+			if !child.matches(row) {
+				return false
+			}
+		}
+		if len(f.or) > 0 {
+			for _, child := range f.or {
+				if child.matches(row) {
+					return true
+				}
+			}
+			return false
+		}
+		return true
+	}
+
+	cell, present := row[f.field]
+
+	switch f.op {
+	case "$eq":
+		return valuesEqual(cell, f.value)
+	case "$ne":
+		return !valuesEqual(cell, f.value)
+	case "$gt", "$gte", "$lt", "$lte":
+		if isEmptyValue(cell) {
+			return false
+		}
+		cmp := compareValues(cell, f.value)
+		switch f.op {
+		case "$gt":
+			return cmp > 0
+		case "$gte":
+			return cmp >= 0
+		case "$lt":
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	case "$in", "$nin":
+		found := false
+		for _, candidate := range f.value.([]interface{}) {
+			if valuesEqual(cell, candidate) {
+				found = true
+				break
+			}
+		}
+		if f.op == "$in" {
+			return found
+		}
+		return !found
+	case "$contains":
+		if list, ok := cell.([]interface{}); ok {
+			for _, item := range list {
+				if stringValue(item) == f.value.(string) {
+					return true
+				}
+			}
+			return false
+		}
+		return !isEmptyValue(cell) && strings.Contains(stringValue(cell), f.value.(string))
+	case "$startsWith":
+		return !isEmptyValue(cell) && strings.HasPrefix(stringValue(cell), f.value.(string))
+	case "$regex":
+		return !isEmptyValue(cell) && f.re.MatchString(stringValue(cell))
+	case "$exists":
+		exists := present && !isEmptyValue(cell)
+		return exists == f.value.(bool)
+	}
+	return false
+}
+
+// filterRows returns the rows matching the parsed where filter
+func filterRows(rows []map[string]interface{}, filter *filterNode) []map[string]interface{} {
+	if filter == nil {
+		return rows
+	}
+
+	filtered := make([]map[string]interface{}, 0)
+	for _, row := range rows {
+		if filter.matches(row) {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered
+}
+
+// isEmptyValue treats nil and blank cells as empty
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	return false
+}
+
+// stringValue renders a cell value the way it would appear in the sheet
+func stringValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// compareValues compares two values numerically when both are numbers,
+// chronologically when both are dates, and as strings otherwise.
+func compareValues(a, b interface{}) int {
+	if af, ok := models.NumericValue(a); ok {
+		if bf, ok := models.NumericValue(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	if at, ok := models.DateValue(a); ok {
+		if bt, ok := models.DateValue(b); ok {
+			return at.Compare(bt)
+		}
+	}
+
+	return strings.Compare(stringValue(a), stringValue(b))
+}
+
+// valuesEqual compares a cell with a filter operand; null matches empty cells
+func valuesEqual(cell, operand interface{}) bool {
+	if operand == nil {
+		return isEmptyValue(cell)
+	}
+	if b, ok := operand.(bool); ok {
+		return strings.EqualFold(strings.TrimSpace(stringValue(cell)), strconv.FormatBool(b))
+	}
+	if cell == nil {
+		return false
+	}
+	return compareValues(cell, operand) == 0
+}
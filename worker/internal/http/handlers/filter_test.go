@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		where string
+		want  string
+	}{
+		{"not an object", `[1,2]`, "where must be a JSON object"},
+		{"unknown logical operator", `{"$not":[{"a":1}]}`, `unknown logical operator "$not"`},
+		{"empty $or", `{"$or":[]}`, "$or expects a non-empty array"},
+		{"$and not an array", `{"$and":{"a":1}}`, "$and expects a non-empty array"},
+		{"$or item not an object", `{"$or":[1]}`, "$or[0] must be an object"},
+		{"nested error", `{"$or":[{"a":{"$bogus":1}}]}`, `unsupported operator "$bogus" on field "a"`},
+		{"unsupported operator", `{"a":{"$like":"x"}}`, `unsupported operator "$like" on field "a"`},
+		{"mixed operators and keys", `{"a":{"$gt":1,"b":2}}`, `field "a" mixes operators with plain keys`},
+		{"$in not an array", `{"a":{"$in":"x"}}`, `$in on field "a" expects an array`},
+		{"$nin not an array", `{"a":{"$nin":3}}`, `$nin on field "a" expects an array`},
+		{"$contains not a string", `{"a":{"$contains":1}}`, `$contains on field "a" expects a string`},
+		{"$startsWith not a string", `{"a":{"$startsWith":true}}`, `$startsWith on field "a" expects a string`},
+		{"$regex not a string", `{"a":{"$regex":1}}`, `$regex on field "a" expects a string`},
+		{"$regex invalid", `{"a":{"$regex":"("}}`, `invalid $regex on field "a"`},
+		{"$exists not a bool", `{"a":{"$exists":"yes"}}`, `$exists on field "a" expects true or false`},
+		{"$gt null", `{"a":{"$gt":null}}`, `$gt on field "a" cannot compare with null`},
+		{"$lte object", `{"a":{"$lte":{"x":1}}}`, `$lte on field "a" expects a number, string or date`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFilter(tt.where)
+			if err == nil {
+				t.Fatalf("parseFilter(%s) succeeded, want error containing %q", tt.where, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseFilter(%s) error = %q, want it to contain %q", tt.where, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterMatches(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"name": "Alice", "price": "12", "status": "active", "tags": []interface{}{"new", "sale"}},
+		{"name": "Bob", "price": "5", "status": "inactive", "featured": true},
+		{"name": "carol", "price": "", "status": "active"},
+	}
+
+	tests := []struct {
+		name  string
+		where string
+		want  []string
+	}{
+		{"empty matches all", ``, []string{"Alice", "Bob", "carol"}},
+		{"plain equality", `{"status":"active"}`, []string{"Alice", "carol"}},
+		{"object without operators is equality", `{"status":{"x":1}}`, nil},
+		{"range", `{"price":{"$gte":5,"$lt":12}}`, []string{"Bob"}},
+		{"range skips empty cells", `{"price":{"$lte":100}}`, []string{"Alice", "Bob"}},
+		{"$ne", `{"status":{"$ne":"active"}}`, []string{"Bob"}},
+		{"$in", `{"name":{"$in":["Alice","Bob"]}}`, []string{"Alice", "Bob"}},
+		{"$nin", `{"name":{"$nin":["Alice","Bob"]}}`, []string{"carol"}},
+		{"$contains list item", `{"tags":{"$contains":"sale"}}`, []string{"Alice"}},
+		{"$startsWith", `{"name":{"$startsWith":"Bo"}}`, []string{"Bob"}},
+		{"$regex", `{"name":{"$regex":"^[a-z]"}}`, []string{"carol"}},
+		{"$exists true", `{"featured":{"$exists":true}}`, []string{"Bob"}},
+		{"$exists false", `{"price":{"$exists":false}}`, []string{"carol"}},
+		{"$or", `{"$or":[{"name":"Alice"},{"featured":true}]}`, []string{"Alice", "Bob"}},
+		{"$and with $or", `{"status":"active","$or":[{"name":"carol"},{"price":{"$gt":100}}]}`, []string{"carol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseFilter(tt.where)
+			if err != nil {
+				t.Fatalf("parseFilter(%s) error = %v", tt.where, err)
+			}
+			var got []string
+			for _, row := range filterRows(rows, filter) {
+				got = append(got, row["name"].(string))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("parseFilter(%s) matched %v, want %v", tt.where, got, tt.want)
+			}
+		})
+	}
+}
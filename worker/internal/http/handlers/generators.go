@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"net/http"
+	"time"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// crockfordAlphabet is the base32 alphabet used by ULIDs
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// idSequence reserves n consecutive auto-increment values of a column, none
+// lower than floor, and returns the first
+type idSequence func(column string, floor int64, n int) (int64, error)
+
+// rowGenerator fills columns that have a generator in the sheet's schema.
+// One generator is used per request so every row gets the same timestamp and
+// auto-increment values keep counting across a bulk write.
+//
+// Auto-increment values are drawn from seq in blocks that double in size, so
+// concurrent writers never hand out the same value; values reserved but not
+// used are skipped. Without seq they are only counted within the request. A
+// failed reservation leaves the value empty and is reported by err.
+type rowGenerator struct {
+	schema   models.ColumnSchema
+	now      time.Time
+	next     map[string]int64 // next auto-increment value per column
+	seq      idSequence
+	reserved map[string]int64 // end (exclusive) of the reserved block per column
+	block    map[string]int   // size of the last block reserved per column
+	err      error
+}
+
+// newRowGenerator prepares generators for a write. Auto-increment columns
+// continue from the largest value among the existing rows, or from the last
+// value seq handed out if that is higher.
+func newRowGenerator(schema models.ColumnSchema, rows []map[string]interface{}, seq idSequence) *rowGenerator {
+	g := &rowGenerator{
+		schema:   schema,
+		now:      time.Now().UTC(),
+		next:     make(map[string]int64),
+		seq:      seq,
+		reserved: make(map[string]int64),
+		block:    make(map[string]int),
+	}
+	for column, def := range schema {
+		if def.Generate != models.GenerateAutoIncrement {
+			continue
+		}
+		next := int64(1)
+		for _, row := range rows {
+			if f, ok := models.NumericValue(row[column]); ok && int64(f) >= next {
+				next = int64(f) + 1
+			}
+		}
+		g.next[column] = next
+	}
+	return g
+}
+
+// insert returns a copy of a new row with every generated column the client
+// left out or empty filled in. Client values are kept; an explicit
+// auto-increment value moves the counter past it.
+func (g *rowGenerator) insert(input map[string]interface{}) map[string]interface{} {
+	row := copyRow(input)
+	for column, def := range g.schema {
+		if def.Generate == "" {
+			continue
+		}
+		if !isEmptyValue(row[column]) {
+			if f, ok := models.NumericValue(row[column]); ok && def.Generate == models.GenerateAutoIncrement && int64(f) >= g.next[column] {
+				g.next[column] = int64(f) + 1
+			}
+			continue
+		}
+		row[column] = g.generate(column, def)
+	}
+	return row
+}
+
+// update returns a copy of the changes for an existing row: now_on_update
+// columns are stamped, and other generated columns that are still empty in
+// the row (and not being set) are filled in
+func (g *rowGenerator) update(data, prev map[string]interface{}) map[string]interface{} {
+	row := copyRow(data)
+	for column, def := range g.schema {
+		switch {
+		case def.Generate == models.GenerateNowOnUpdate:
+			row[column] = g.generate(column, def)
+		case def.Generate != "":
+			if _, set := row[column]; !set && isEmptyValue(prev[column]) {
+				row[column] = g.generate(column, def)
+			}
+		}
+	}
+	return row
+}
+
+// generate produces the next value for a column
+func (g *rowGenerator) generate(column string, def models.ColumnDef) interface{} {
+	switch def.Generate {
+	case models.GenerateUUID:
+		return uuid.New().String()
+	case models.GenerateULID:
+		return newULID(g.now)
+	case models.GenerateAutoIncrement:
+		return g.nextID(column)
+	case models.GenerateNowOnCreate, models.GenerateNowOnUpdate:
+		if def.Type == models.ColumnDate {
+			return g.now.Format("2006-01-02")
+		}
+		return g.now.Format(time.RFC3339)
+	}
+	return nil
+}
+
+// nextID returns the next auto-increment value of a column, reserving a new
+// block from the sequence once the current one is used up
+func (g *rowGenerator) nextID(column string) interface{} {
+	n := g.next[column]
+	if g.seq != nil && n >= g.reserved[column] {
+		size := g.block[column] * 2
+		if size == 0 {
+			size = 1
+		}
+		first, err := g.seq(column, n, size)
+		if err != nil {
+			if g.err == nil {
+				g.err = err
+			}
+			return nil
+		}
+		n = first
+		g.reserved[column] = first + int64(size)
+		g.block[column] = size
+	}
+	g.next[column] = n + 1
+	return n
+}
+
+// generatorFailed answers 500 when auto-increment values could not be reserved
+func generatorFailed(c *gin.Context, g *rowGenerator) bool {
+	if g.err == nil {
+		return false
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reserve auto_increment values", "details": g.err.Error()})
+	return true
+}
+
+// copyRow returns a shallow copy of a row
+func copyRow(row map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		copied[k] = v
+	}
+	return copied
+}
+
+// newULID returns a ULID: a 48-bit millisecond timestamp followed by 80
+// random bits, as 26 Crockford base32 characters that sort by time
+func newULID(t time.Time) string {
+	var id [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+	_, _ = rand.Read(id[6:])
+
+	// 128 bits as 26 five-bit groups, the first holding only the top 3 bits
+	out := make([]byte, 26)
+	var acc uint64
+	bits := 2 // pad to 130 bits so the groups line up
+	pos := 0
+	for _, b := range id {
+		acc = acc<<8 | uint64(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockfordAlphabet[(acc>>uint(bits))&31]
+			pos++
+		}
+	}
+	return string(out)
+}
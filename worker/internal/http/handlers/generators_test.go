@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gsheetbase/shared/models"
+)
+
+// fakeSequence mimics SequenceRepo.Reserve for one column, recording the size
+// of each block requested
+type fakeSequence struct {
+	last   int64
+	blocks []int
+}
+
+func (s *fakeSequence) reserve(column string, floor int64, n int) (int64, error) {
+	if s.last < floor-1 {
+		s.last = floor - 1
+	}
+	s.last += int64(n)
+	s.blocks = append(s.blocks, n)
+	return s.last - int64(n) + 1, nil
+}
+
+func TestNewULID(t *testing.T) {
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	id := newULID(at)
+	if len(id) != 26 {
+		t.Fatalf("len(%q) = %d, want 26", id, len(id))
+	}
+	for _, r := range id {
+		if !strings.ContainsRune(crockfordAlphabet, r) {
+			t.Fatalf("%q contains %q outside the Crockford alphabet", id, r)
+		}
+	}
+	if first := id[0]; first > '7' {
+		t.Errorf("first character %q holds more than 3 bits", first)
+	}
+	if later := newULID(at.Add(time.Millisecond)); later[:10] <= id[:10] {
+		t.Errorf("timestamp part %q of a later ULID does not sort after %q", later[:10], id[:10])
+	}
+}
+
+func TestRowGeneratorAutoIncrement(t *testing.T) {
+	schema := models.ColumnSchema{"id": {Type: models.ColumnInteger, Generate: models.GenerateAutoIncrement}}
+
+	tests := []struct {
+		name     string
+		existing []map[string]interface{}
+		inputs   []map[string]interface{}
+		want     []interface{}
+	}{
+		{
+			name:   "empty sheet starts at 1",
+			inputs: []map[string]interface{}{{}, {}},
+			want:   []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:     "continues from the largest existing value",
+			existing: []map[string]interface{}{{"id": "4"}, {"id": "9"}, {"id": ""}},
+			inputs:   []map[string]interface{}{{}, {"id": ""}},
+			want:     []interface{}{int64(10), int64(11)},
+		},
+		{
+			name:   "explicit value moves the counter past it",
+			inputs: []map[string]interface{}{{}, {"id": float64(20)}, {}},
+			want:   []interface{}{int64(1), float64(20), int64(21)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newRowGenerator(schema, tt.existing, nil)
+			var got []interface{}
+			for _, input := range tt.inputs {
+				got = append(got, g.insert(input)["id"])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowGeneratorSequence(t *testing.T) {
+	schema := models.ColumnSchema{"id": {Type: models.ColumnInteger, Generate: models.GenerateAutoIncrement}}
+
+	tests := []struct {
+		name       string
+		last       int64
+		existing   []map[string]interface{}
+		rows       int
+		wantFirst  int64
+		wantBlocks []int
+	}{
+		{"blocks double in size", 0, nil, 7, 1, []int{1, 2, 4}},
+		{"sequence ahead of the sheet", 40, []map[string]interface{}{{"id": "5"}}, 2, 41, []int{1, 2}},
+		{"sheet ahead of the sequence", 3, []map[string]interface{}{{"id": "5"}}, 1, 6, []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := &fakeSequence{last: tt.last}
+			g := newRowGenerator(schema, tt.existing, seq.reserve)
+			for i := 0; i < tt.rows; i++ {
+				want := tt.wantFirst + int64(i)
+				if got := g.insert(map[string]interface{}{})["id"]; got != want {
+					t.Errorf("row %d id = %v, want %d", i, got, want)
+				}
+			}
+			if g.err != nil {
+				t.Errorf("err = %v", g.err)
+			}
+			if !reflect.DeepEqual(seq.blocks, tt.wantBlocks) {
+				t.Errorf("blocks = %v, want %v", seq.blocks, tt.wantBlocks)
+			}
+		})
+	}
+}
+
+func TestRowGeneratorSequenceError(t *testing.T) {
+	schema := models.ColumnSchema{"id": {Type: models.ColumnInteger, Generate: models.GenerateAutoIncrement}}
+	failure := errors.New("database unavailable")
+	g := newRowGenerator(schema, nil, func(string, int64, int) (int64, error) { return 0, failure })
+
+	if got := g.insert(map[string]interface{}{})["id"]; got != nil {
+		t.Errorf("id = %v, want nil after a failed reservation", got)
+	}
+	if !errors.Is(g.err, failure) {
+		t.Errorf("err = %v, want %v", g.err, failure)
+	}
+}
+
+func TestRowGeneratorTimestamps(t *testing.T) {
+	schema := models.ColumnSchema{
+		"created": {Type: models.ColumnDatetime, Generate: models.GenerateNowOnCreate},
+		"updated": {Type: models.ColumnDate, Generate: models.GenerateNowOnUpdate},
+		"ref":     {Type: models.ColumnString, Generate: models.GenerateUUID},
+	}
+	g := newRowGenerator(schema, nil, nil)
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		prev map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "stamps updated and fills empty generated columns",
+			data: map[string]interface{}{"name": "x"},
+			prev: map[string]interface{}{"ref": "r-1"},
+			want: map[string]interface{}{"name": "x", "created": g.now.Format(time.RFC3339), "updated": g.now.Format("2006-01-02")},
+		},
+		{
+			name: "keeps values being set",
+			data: map[string]interface{}{"created": "2020-01-01T00:00:00Z"},
+			prev: map[string]interface{}{"created": "2019-01-01T00:00:00Z", "ref": "r-1"},
+			want: map[string]interface{}{"created": "2020-01-01T00:00:00Z", "updated": g.now.Format("2006-01-02")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.update(tt.data, tt.prev); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("update = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
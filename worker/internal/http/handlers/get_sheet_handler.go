@@ -2,18 +2,21 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"time"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/worker/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetPublic handles GET /v1/:api_key?collection=Sheet1&fields=asset,location&where={"owner":"Homeowner"}&orderBy=asset&limit=2&offset=0
+// GetPublic handles GET /v1/:api_key?collection=Sheet1&fields=asset,location&where={"owner":"Homeowner"}&orderBy=-price,asset&limit=2&offset=0
+// Passing cursor (empty for the first page) switches from offset to cursor pagination.
+// Rows can also be returned as CSV, NDJSON or XLSX via format= or the Accept header;
+// download=1 marks the response as a file attachment. q= runs a full-text search,
+// with score=1 and highlight=1 adding the relevance score and matched spans.
 func (h *SheetHandler) GetPublic(c *gin.Context) {
-	apiKey := c.Param("api_key")
-	if apiKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "api_key is required"})
-		return
-	}
-
 	// Parse query params
 	collection := c.Query("collection")
 	fields := c.Query("fields")
@@ -21,11 +24,35 @@ func (h *SheetHandler) GetPublic(c *gin.Context) {
 	offset := c.DefaultQuery("offset", "0")
 	orderBy := c.Query("orderBy")
 	where := c.Query("where")
+	cursor, cursorMode := c.GetQuery("cursor")
+	download := c.Query("download") == "1"
+	search := parseSearch(c.Query("q"))
+	withScore := c.Query("score") == "1"
+	withHighlight := c.Query("highlight") == "1"
 
-	// Find the sheet by API key
-	sheet, err := h.sheetRepo.FindByAPIKey(c.Request.Context(), apiKey)
+	format, err := negotiateFormat(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "invalid api key or sheet not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format", "details": err.Error()})
+		return
+	}
+
+	// Validate the where filter before touching the sheet
+	filter, err := parseFilter(where)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
+		return
+	}
+
+	sortKeys, err := parseOrderBy(orderBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid orderBy", "details": err.Error()})
+		return
+	}
+
+	// The sheet was resolved by SheetAuthMiddleware (api_key or Authorization header)
+	sheet, ok := middleware.SheetFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet not resolved"})
 		return
 	}
 
@@ -35,14 +62,28 @@ func (h *SheetHandler) GetPublic(c *gin.Context) {
 		return
 	}
 
-	c.Set("sheet_id", sheet.ID)
-	c.Set("user_id", user.ID)
-
 	if user.GoogleAccessToken == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet owner needs to reconnect Google account"})
 		return
 	}
 
+	// Headerless sheets address columns by letter or 0-based index
+	useHeader := sheet.UseFirstRowAsHeader
+	if !useHeader {
+		if fields, err = positionalFields(fields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid fields", "details": err.Error()})
+			return
+		}
+		if err := positionalFilter(filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
+			return
+		}
+		if err := positionalSortKeys(sortKeys); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid orderBy", "details": err.Error()})
+			return
+		}
+	}
+
 	// Determine range
 	fetchRange := collection
 	if fetchRange == "" && sheet.DefaultRange != nil {
@@ -51,6 +92,9 @@ func (h *SheetHandler) GetPublic(c *gin.Context) {
 	if fetchRange == "" {
 		fetchRange = "Sheet1"
 	}
+	if !requireScope(c, models.ScopeRead, fetchRange) {
+		return
+	}
 
 	// Fetch sheet data
 	data, err := h.fetchSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, fetchRange)
@@ -58,25 +102,96 @@ func (h *SheetHandler) GetPublic(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet data", "details": err.Error()})
 		return
 	}
+	fetchedAt := time.Now()
+	limits := user.GetPlanLimits()
 
-	// Transform to JSON
-	rows := transformToJSON(data)
+	// Transform to JSON and coerce cells to their column types
+	rows := sheetRows(data, useHeader)
+	schema := resolveColumnSchema(sheet.ColumnSchema, rows)
+	rows = coerceRows(rows, schema)
+	attachRowNumbers(rows, rangeStartRow(fetchRange)+headerRowCount(useHeader))
+	attachRowVersions(rows, data, headerRowCount(useHeader))
+	columns := exportColumns(data, fields, useHeader)
 
-	// Apply simple where filter (equality only)
-	filtered := filterRows(rows, where)
+	// Apply where filter and full-text search
+	filtered := filterRows(rows, filter)
+	searchCols := searchColumns(sheet.SearchColumns, rows)
+	filtered = searchRows(filtered, search, searchCols)
+
+	// Search results are ranked by relevance unless an order is requested
+	if search != nil && len(sortKeys) == 0 {
+		sortKeys = []sortKey{{field: searchScoreKey, descending: true}}
+	}
+
+	// Cursor pagination: keyset over the ordering plus a stable row identity
+	if cursorMode {
+		cursorLimit, err := strconv.Atoi(limit)
+		if err != nil || cursorLimit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+
+		keys := cursorSortKeys(sortKeys, sheet.PrimaryKeyColumn)
+		ordered := orderRows(filtered, keys, schema)
+		page, nextCursor, hasMore, err := cursorPage(ordered, keys, schema, cursor, orderBy, cursorLimit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor", "details": err.Error()})
+			return
+		}
+
+		selected := stripRowNumbers(selectFields(page, fields))
+		finishSearch(selected, search, searchCols, withScore, withHighlight)
+		if format != formatJSON {
+			if hasMore {
+				c.Header("Link", nextPageLink(c.Request.URL, nextCursor))
+			}
+			writeExport(c, format, columns, selected, !useHeader, download, fetchRange, fetchedAt, limits)
+			return
+		}
+
+		if useHeader {
+			setRowVersion(c, selected)
+		}
+		pagination := gin.H{
+			"limit":    cursorLimit,
+			"has_more": hasMore,
+		}
+		if hasMore {
+			pagination["next_cursor"] = nextCursor
+			c.Header("Link", nextPageLink(c.Request.URL, nextCursor))
+		}
+		writeConditionalJSON(c, gin.H{"data": responseData(selected, columns, useHeader), "pagination": pagination}, fetchedAt, limits)
+		return
+	}
+
+	// Order by (before selecting fields so any column can be a sort key)
+	ordered := orderRows(filtered, sortKeys, schema)
 
 	// Select fields
-	selected := selectFields(filtered, fields)
+	selected := stripRowNumbers(selectFields(ordered, fields))
 
-	// Order by
-	ordered := orderRows(selected, orderBy)
+	// Exports return every matching row unless limit/offset are given
+	hasExplicitPagination := c.Query("limit") != "" || c.Query("offset") != ""
+	if format != formatJSON {
+		if hasExplicitPagination {
+			selected, _ = paginateRows(selected, limit, offset)
+		}
+		finishSearch(selected, search, searchCols, withScore, withHighlight)
+		writeExport(c, format, columns, selected, !useHeader, download, fetchRange, fetchedAt, limits)
+		return
+	}
 
 	// Pagination only if explicitly set
-	paginated, pagination := paginateRows(ordered, limit, offset)
-	hasExplicitPagination := c.Query("limit") != "" || c.Query("offset") != ""
+	paginated, pagination := paginateRows(selected, limit, offset)
+	finishSearch(paginated, search, searchCols, withScore, withHighlight)
+	// A single row also reports its version in a header, ready for If-Match;
+	// the ETag stays a hash of this representation for If-None-Match
+	if useHeader {
+		setRowVersion(c, paginated)
+	}
 	if hasExplicitPagination && pagination != nil {
-		c.JSON(http.StatusOK, gin.H{"data": paginated, "pagination": pagination})
+		writeConditionalJSON(c, gin.H{"data": responseData(paginated, columns, useHeader), "pagination": pagination}, fetchedAt, limits)
 	} else {
-		c.JSON(http.StatusOK, gin.H{"data": paginated})
+		writeConditionalJSON(c, gin.H{"data": responseData(paginated, columns, useHeader)}, fetchedAt, limits)
 	}
 }
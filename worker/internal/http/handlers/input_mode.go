@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"gsheetbase/shared/models"
+)
+
+// formulaPrefixes are the leading characters that make Sheets treat typed
+// text as a formula
+const formulaPrefixes = "=+-@"
+
+// valueInputOption returns the Sheets ValueInputOption writes use for a value
+// input mode. SANITIZED values are parsed like USER_ENTERED ones, with the
+// text that would be read as a formula escaped by inputCell.
+func valueInputOption(mode string) string {
+	if mode == models.InputModeRaw {
+		return "RAW"
+	}
+	return "USER_ENTERED"
+}
+
+// inputCell applies the sheet's value input mode to one client value. In
+// SANITIZED mode, text that would otherwise be read as a formula is stored
+// verbatim by prefixing it with an apostrophe, which Sheets drops (plain
+// numbers such as "-5" are left alone). RAW and USER_ENTERED values are left
+// to the ValueInputOption.
+func inputCell(value interface{}, mode string) interface{} {
+	s, ok := value.(string)
+	if !ok || s == "" || mode != models.InputModeSanitized {
+		return value
+	}
+	if strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "'" + s
+		}
+	}
+	return value
+}
+
+// inputRow applies the value input mode to a row of client values
+func inputRow(values []interface{}, mode string) []interface{} {
+	if mode != models.InputModeSanitized {
+		return values
+	}
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		row[i] = inputCell(v, mode)
+	}
+	return row
+}
+
+// inputData applies the value input mode to the fields of an update
+func inputData(data map[string]interface{}, mode string) map[string]interface{} {
+	if mode != models.InputModeSanitized {
+		return data
+	}
+	prepared := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		prepared[k] = inputCell(v, mode)
+	}
+	return prepared
+}
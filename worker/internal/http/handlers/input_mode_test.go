@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"gsheetbase/shared/models"
+)
+
+func TestInputCell(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		mode  string
+		want  interface{}
+	}{
+		{"sanitized formula", "=SUM(A1:A3)", models.InputModeSanitized, "'=SUM(A1:A3)"},
+		{"sanitized plus", "+cmd", models.InputModeSanitized, "'+cmd"},
+		{"sanitized minus text", "-x", models.InputModeSanitized, "'-x"},
+		{"sanitized at", "@SUM(1)", models.InputModeSanitized, "'@SUM(1)"},
+		{"sanitized negative number", "-5", models.InputModeSanitized, "-5"},
+		{"sanitized signed decimal", "+1.5", models.InputModeSanitized, "+1.5"},
+		{"sanitized plain text", "hello = world", models.InputModeSanitized, "hello = world"},
+		{"sanitized empty", "", models.InputModeSanitized, ""},
+		{"sanitized non-string", float64(3), models.InputModeSanitized, float64(3)},
+		{"raw formula", "=SUM(A1:A3)", models.InputModeRaw, "=SUM(A1:A3)"},
+		{"user entered formula", "=SUM(A1:A3)", models.InputModeUserEntered, "=SUM(A1:A3)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inputCell(tt.value, tt.mode); got != tt.want {
+				t.Errorf("inputCell(%v, %s) = %v, want %v", tt.value, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueInputOption(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{models.InputModeRaw, "RAW"},
+		{models.InputModeUserEntered, "USER_ENTERED"},
+		{models.InputModeSanitized, "USER_ENTERED"},
+	}
+
+	for _, tt := range tests {
+		if got := valueInputOption(tt.mode); got != tt.want {
+			t.Errorf("valueInputOption(%s) = %s, want %s", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestInputRowAndData(t *testing.T) {
+	tests := []struct {
+		mode     string
+		wantRow  []interface{}
+		wantData map[string]interface{}
+	}{
+		{
+			mode:     models.InputModeSanitized,
+			wantRow:  []interface{}{"'=1+1", "-2", true},
+			wantData: map[string]interface{}{"a": "'=1+1", "b": "-2", "c": true},
+		},
+		{
+			mode:     models.InputModeRaw,
+			wantRow:  []interface{}{"=1+1", "-2", true},
+			wantData: map[string]interface{}{"a": "=1+1", "b": "-2", "c": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			row := []interface{}{"=1+1", "-2", true}
+			if got := inputRow(row, tt.mode); !reflect.DeepEqual(got, tt.wantRow) {
+				t.Errorf("inputRow = %v, want %v", got, tt.wantRow)
+			}
+			data := map[string]interface{}{"a": "=1+1", "b": "-2", "c": true}
+			if got := inputData(data, tt.mode); !reflect.DeepEqual(got, tt.wantData) {
+				t.Errorf("inputData = %v, want %v", got, tt.wantData)
+			}
+			if row[0] != "=1+1" || data["a"] != "=1+1" {
+				t.Error("input was modified in place")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"gsheetbase/shared/models"
+)
+
+// rowNumberKey holds the sheet row number of each data row while a request
+// is processed. It is stripped before rows are returned to the client.
+const rowNumberKey = "_row"
+
+// pageCursor is the decoded form of an opaque cursor. It records the sort key
+// values of the last row served, so the next page starts strictly after it
+// even if rows were inserted or removed elsewhere in the sheet.
+type pageCursor struct {
+	OrderBy string        `json:"o"`
+	Values  []interface{} `json:"v"`
+}
+
+// attachRowNumbers records the sheet row number of each data row.
+// firstRow is the 1-based sheet row of the first data row.
+func attachRowNumbers(rows []map[string]interface{}, firstRow int) {
+	for i, row := range rows {
+		row[rowNumberKey] = firstRow + i
+	}
+}
+
+// stripRowNumbers removes the internal row number from rows before responding
+func stripRowNumbers(rows []map[string]interface{}) []map[string]interface{} {
+	for _, row := range rows {
+		delete(row, rowNumberKey)
+	}
+	return rows
+}
+
+// rangeStartRow returns the 1-based sheet row a range starts at ("Sheet1!A5:D" → 5)
+func rangeStartRow(rangeStr string) int {
+	_, index, err := parseRange(rangeStr)
+	if err != nil || index < 0 {
+		return 1
+	}
+	return int(index) + 1
+}
+
+// cursorSortKeys extends the requested sort keys with the row identity so
+// every row has a unique position: the primary-key column when configured,
+// then the sheet row number.
+func cursorSortKeys(keys []sortKey, primaryKey *string) []sortKey {
+	cursorKeys := append([]sortKey{}, keys...)
+	if primaryKey != nil && *primaryKey != "" {
+		cursorKeys = append(cursorKeys, sortKey{field: *primaryKey})
+	}
+	return append(cursorKeys, sortKey{field: rowNumberKey})
+}
+
+// encodeCursor builds the opaque cursor pointing after the given row
+func encodeCursor(row map[string]interface{}, keys []sortKey, orderBy string) string {
+	cursor := pageCursor{OrderBy: orderBy, Values: make([]interface{}, len(keys))}
+	for i, key := range keys {
+		cursor.Values[i] = row[key.field]
+	}
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses an opaque cursor and checks it was issued for the same ordering
+func decodeCursor(raw string, keys []sortKey, orderBy string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+	if cursor.OrderBy != orderBy || len(cursor.Values) != len(keys) {
+		return nil, fmt.Errorf("cursor was issued for a different orderBy")
+	}
+	return &cursor, nil
+}
+
+// cursorPage returns up to limit rows that sort after the cursor, the cursor
+// for the following page and whether more rows remain. rows must already be
+// ordered by keys.
+func cursorPage(rows []map[string]interface{}, keys []sortKey, schema models.ColumnSchema, rawCursor, orderBy string, limit int) ([]map[string]interface{}, string, bool, error) {
+	start := 0
+	if rawCursor != "" {
+		cursor, err := decodeCursor(rawCursor, keys, orderBy)
+		if err != nil {
+			return nil, "", false, err
+		}
+		last := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			last[key.field] = cursor.Values[i]
+		}
+		start = len(rows)
+		for i, row := range rows {
+			if compareRows(row, last, keys, schema) > 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	end := start + limit
+	if end > len(rows) {
+		end = len(rows)
+	}
+	page := rows[start:end]
+	hasMore := end < len(rows)
+
+	nextCursor := ""
+	if hasMore && len(page) > 0 {
+		nextCursor = encodeCursor(page[len(page)-1], keys, orderBy)
+	}
+	return page, nextCursor, hasMore, nil
+}
+
+// nextPageLink builds the RFC 8288 Link header value for the next page
+func nextPageLink(u *url.URL, nextCursor string) string {
+	query := u.Query()
+	query.Set("cursor", nextCursor)
+	query.Del("offset")
+	next := url.URL{Path: u.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf("<%s>; rel=\"next\"", next.String())
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gsheetbase/shared/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	primaryKey := "id"
+	tests := []struct {
+		name    string
+		row     map[string]interface{}
+		keys    []sortKey
+		orderBy string
+		want    []interface{}
+	}{
+		{
+			name: "row number only",
+			row:  map[string]interface{}{"name": "Alice", rowNumberKey: 7},
+			keys: cursorSortKeys(nil, nil),
+			want: []interface{}{float64(7)},
+		},
+		{
+			name:    "sort keys then primary key",
+			row:     map[string]interface{}{"name": "Alice", "id": "a-1", rowNumberKey: 3},
+			keys:    cursorSortKeys([]sortKey{{field: "name", descending: true}}, &primaryKey),
+			orderBy: "-name",
+			want:    []interface{}{"Alice", "a-1", float64(3)},
+		},
+		{
+			name:    "missing values",
+			row:     map[string]interface{}{rowNumberKey: 2},
+			keys:    cursorSortKeys([]sortKey{{field: "price"}}, nil),
+			orderBy: "price",
+			want:    []interface{}{nil, float64(2)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := encodeCursor(tt.row, tt.keys, tt.orderBy)
+			cursor, err := decodeCursor(raw, tt.keys, tt.orderBy)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q) error = %v", raw, err)
+			}
+			if cursor.OrderBy != tt.orderBy {
+				t.Errorf("OrderBy = %q, want %q", cursor.OrderBy, tt.orderBy)
+			}
+			if !reflect.DeepEqual(cursor.Values, tt.want) {
+				t.Errorf("Values = %#v, want %#v", cursor.Values, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	keys := cursorSortKeys([]sortKey{{field: "name"}}, nil)
+	valid := encodeCursor(map[string]interface{}{"name": "Alice", rowNumberKey: 2}, keys, "name")
+
+	tests := []struct {
+		name    string
+		raw     string
+		keys    []sortKey
+		orderBy string
+		want    string
+	}{
+		{"not base64", "!!!", keys, "name", "cursor is malformed"},
+		{"not JSON", base64.RawURLEncoding.EncodeToString([]byte("nope")), keys, "name", "cursor is malformed"},
+		{"different orderBy", valid, keys, "-name", "different orderBy"},
+		{"different key count", valid, cursorSortKeys(nil, nil), "name", "different orderBy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.raw, tt.keys, tt.orderBy)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("decodeCursor(%q) error = %v, want %q", tt.raw, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursorPage(t *testing.T) {
+	rows := make([]map[string]interface{}, 5)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"n": i + 1}
+	}
+	attachRowNumbers(rows, 2)
+	keys := cursorSortKeys(nil, nil)
+
+	var got []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(rows) {
+			t.Fatal("cursor pagination did not terminate")
+		}
+		page, next, hasMore, err := cursorPage(rows, keys, models.ColumnSchema{}, cursor, "", 2)
+		if err != nil {
+			t.Fatalf("cursorPage error = %v", err)
+		}
+		for _, row := range page {
+			got = append(got, row["n"].(int))
+		}
+		if hasMore != (next != "") {
+			t.Fatalf("hasMore = %v with next cursor %q", hasMore, next)
+		}
+		if !hasMore {
+			break
+		}
+		cursor = next
+	}
+
+	if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages returned %v, want %v", got, want)
+	}
+}
+
+func TestRangeStartRow(t *testing.T) {
+	tests := []struct {
+		rangeStr string
+		want     int
+	}{
+		{"Sheet1", 1},
+		{"Sheet1!A5:D", 5},
+		{"Sheet1!A1:D10", 1},
+	}
+
+	for _, tt := range tests {
+		if got := rangeStartRow(tt.rangeStr); got != tt.want {
+			t.Errorf("rangeStartRow(%q) = %d, want %d", tt.rangeStr, got, tt.want)
+		}
+	}
+}
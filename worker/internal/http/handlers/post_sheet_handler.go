@@ -1,23 +1,24 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 
+	"gsheetbase/shared/models"
+	"gsheetbase/worker/internal/middleware"
+
 	"github.com/gin-gonic/gin"
 )
 
-// PostPublic handles POST /v1/:api_key/rows - Append new rows
+// PostPublic handles POST /v1/:api_key/rows - Append new rows.
+// data is one object, or an array of objects appended with a single call.
+// With upsert=1, rows whose key (on_conflict or the primary key) already
+// exists are updated instead.
 func (h *SheetHandler) PostPublic(c *gin.Context) {
-	apiKey := c.Param("api_key")
-	if apiKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "api_key is required"})
-		return
-	}
-
 	var req struct {
-		Collection string                 `json:"collection"`
-		Data       map[string]interface{} `json:"data" binding:"required"`
-		Returning  []string               `json:"returning"`
+		Collection string          `json:"collection"`
+		Data       json.RawMessage `json:"data" binding:"required"`
+		Returning  []string        `json:"returning"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -25,10 +26,16 @@ func (h *SheetHandler) PostPublic(c *gin.Context) {
 		return
 	}
 
-	// Find the sheet by API key
-	sheet, err := h.sheetRepo.FindByAPIKey(c.Request.Context(), apiKey)
+	inputs, bulk, err := parseWriteRows(req.Data)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "invalid api key or sheet not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+		return
+	}
+
+	// The sheet was resolved by SheetAuthMiddleware (api_key or Authorization header)
+	sheet, ok := middleware.SheetFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet not resolved"})
 		return
 	}
 
@@ -38,6 +45,19 @@ func (h *SheetHandler) PostPublic(c *gin.Context) {
 		return
 	}
 
+	upsert := c.Query("upsert") == "1"
+	var keyColumn string
+	if upsert {
+		if !upsertAllowed(sheet.AllowedMethods) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "upsert requires POST and PUT or PATCH to be enabled for this sheet"})
+			return
+		}
+		if keyColumn, err = upsertKeyColumn(c.Query("on_conflict"), sheet); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no key column for upsert", "details": err.Error()})
+			return
+		}
+	}
+
 	// Get the user to access their Google tokens
 	user, err := h.userRepo.FindByID(c.Request.Context(), sheet.UserID)
 	if err != nil {
@@ -45,15 +65,19 @@ func (h *SheetHandler) PostPublic(c *gin.Context) {
 		return
 	}
 
-	// Set context values for usage tracking middleware
-	c.Set("sheet_id", sheet.ID)
-	c.Set("user_id", user.ID)
-
 	if user.GoogleAccessToken == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet owner needs to reconnect Google account"})
 		return
 	}
 
+	limits := user.GetPlanLimits()
+	if bulk && tooManyBulkRows(c, limits, len(inputs)) {
+		return
+	}
+	if exceedsQuota(c, limits.GetBulkWriteUnits(len(inputs))) {
+		return
+	}
+
 	// Determine range (use collection as sheet name, fallback to default)
 	targetRange := req.Collection
 	if targetRange == "" && sheet.DefaultRange != nil {
@@ -62,64 +86,102 @@ func (h *SheetHandler) PostPublic(c *gin.Context) {
 	if targetRange == "" {
 		targetRange = "Sheet1"
 	}
+	if !requireScope(c, models.ScopeWrite, targetRange) {
+		return
+	}
 
-	headerRange := targetRange + "!1:1"
-
-	// fetch header row
-	headerData, err := h.fetchSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, headerRange)
-	if err != nil || len(headerData) == 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet headers", "details": err.Error()})
+	if upsert {
+		h.upsertRows(c, sheet, *user.GoogleAccessToken, targetRange, inputs, bulk, keyColumn, req.Returning, limits)
 		return
 	}
-	headers := headerData[0]
 
-	// Validate the json input
-	row, err := validateAndMap(headers, req.Data)
+	useHeader := sheet.UseFirstRowAsHeader
+	var headers []interface{}
+	var existing []map[string]interface{}
+	if sheet.ColumnSchema.HasGenerator(models.GenerateAutoIncrement) {
+		// Auto-increment continues from the largest value already in the sheet
+		sheetData, err := h.fetchSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet data", "details": err.Error()})
+			return
+		}
+		if useHeader && len(sheetData) > 0 {
+			headers = sheetData[0]
+		}
+		existing = sheetRows(sheetData, useHeader)
+	} else if useHeader {
+		// fetch header row
+		headerData, err := h.fetchSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange+"!1:1")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet headers", "details": err.Error()})
+			return
+		}
+		if len(headerData) > 0 {
+			headers = headerData[0]
+		}
+	}
+	if useHeader && len(headers) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet headers"})
+		return
+	}
 
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to validate data", "details": err.Error()})
+	// Validate every row before writing anything
+	rows := make([][]interface{}, 0, len(inputs))
+	var rowErrors []gin.H
+	width := 0
+	generator := newRowGenerator(sheet.ColumnSchema, existing, h.sequence(c.Request.Context(), sheet, targetRange))
+	for i, input := range inputs {
+		// Headerless sheets key generated columns by letter
+		if !useHeader {
+			if input, err = positionalData(input); err != nil {
+				rowErrors = append(rowErrors, rowError(i, err))
+				continue
+			}
+		}
+		row, err := mapWriteRow(generator.insert(input), headers, useHeader, sheet.ColumnSchema, sheet.StrictFields)
+		if err != nil {
+			rowErrors = append(rowErrors, rowError(i, err))
+			continue
+		}
+		if len(row) > width {
+			width = len(row)
+		}
+		rows = append(rows, inputRow(row, sheet.InputMode()))
+	}
+	if generatorFailed(c, generator) {
 		return
 	}
+	if len(rowErrors) > 0 {
+		rowsFailed(c, rowErrors, bulk)
+		return
+	}
+	if !useHeader {
+		headers = columnHeaders(width)
+	}
 
-	// Append the row and get the appended values from the API response
-	appendResp, err := h.appendSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange, [][]interface{}{row})
+	// Append the rows and get the appended values from the API response
+	appendResp, err := h.appendSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange, rows, sheet.InputMode())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to append data", "details": err.Error()})
 		return
 	}
-	// Use the values returned in the response
-	var createdRow map[string]interface{}
-	if appendResp != nil && appendResp.Updates != nil && appendResp.Updates.UpdatedData != nil && len(appendResp.Updates.UpdatedData.Values) > 0 {
-		createdRow = map[string]interface{}{}
-		for i, h := range headers {
-			headerStr, _ := h.(string)
-			if i < len(appendResp.Updates.UpdatedData.Values[0]) {
-				createdRow[headerStr] = appendResp.Updates.UpdatedData.Values[0][i]
-			} else {
-				createdRow[headerStr] = nil
-			}
-		}
-	} else {
-		// Fallback: map input row
-		createdRow = map[string]interface{}{}
-		for i, h := range headers {
-			headerStr, _ := h.(string)
-			if i < len(row) {
-				createdRow[headerStr] = row[i]
-			} else {
-				createdRow[headerStr] = nil
-			}
-		}
+
+	// Use the values returned in the response, falling back to the input rows
+	written := rows
+	if appendResp != nil && appendResp.Updates != nil && appendResp.Updates.UpdatedData != nil && len(appendResp.Updates.UpdatedData.Values) == len(rows) {
+		written = appendResp.Updates.UpdatedData.Values
+	}
+	created := make([]map[string]interface{}, 0, len(written))
+	for _, values := range written {
+		created = append(created, versionedRow(headers, values, sheet.ColumnSchema, req.Returning))
 	}
 
-	// If returning fields specified, filter
-	if len(req.Returning) > 0 {
-		filtered := make(map[string]interface{})
-		for _, k := range req.Returning {
-			filtered[k] = createdRow[k]
-		}
-		createdRow = filtered
+	if !bulk {
+		setRowVersion(c, created)
+		c.JSON(http.StatusCreated, gin.H{"data": created[0]})
+		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": createdRow})
+	c.Set("usage_units", limits.GetBulkWriteUnits(len(rows)))
+	c.JSON(http.StatusCreated, gin.H{"data": created, "inserted": len(created)})
 }
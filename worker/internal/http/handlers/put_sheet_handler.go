@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/worker/internal/middleware"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/api/sheets/v4"
 )
 
 // PutPublic handles PUT /v1/:api_key/rows - Update/replace rows
@@ -12,18 +18,17 @@ func (h *SheetHandler) PutPublic(c *gin.Context) {
 	h.updateSheetRows(c, "PUT")
 }
 
-// updateSheetRows contains the shared logic for updating sheet rows, used by both PUT and PATCH
+// updateSheetRows contains the shared logic for updating sheet rows, used by both PUT and PATCH.
+// Every row matching all where conditions (same operators as reads) is updated,
+// up to limit rows in sheet order. Only rows whose values change are written.
+// With on_conflict the body is an upsert instead (see upsertRows).
 func (h *SheetHandler) updateSheetRows(c *gin.Context, method string) {
-	apiKey := c.Param("api_key")
-	if apiKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "api_key is required"})
-		return
-	}
-
 	var req struct {
 		Collection string                 `json:"collection"`
 		Where      map[string]interface{} `json:"where"`
-		Data       map[string]interface{} `json:"data" binding:"required"`
+		Data       json.RawMessage        `json:"data" binding:"required"`
+		Limit      *int                   `json:"limit"`
+		OnConflict string                 `json:"on_conflict"`
 		Returning  []string               `json:"returning"`
 	}
 
@@ -32,10 +37,38 @@ func (h *SheetHandler) updateSheetRows(c *gin.Context, method string) {
 		return
 	}
 
-	// Find the sheet by API key
-	sheet, err := h.sheetRepo.FindByAPIKey(c.Request.Context(), apiKey)
+	inputs, bulk, err := parseWriteRows(req.Data)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "invalid api key or sheet not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+		return
+	}
+
+	upsert := req.OnConflict != ""
+	if !upsert && bulk {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": "data must be an object unless on_conflict is set"})
+		return
+	}
+
+	var filter *filterNode
+	if !upsert {
+		if len(req.Where) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "where is required to select the rows to update"})
+			return
+		}
+		if filter, err = parseFilterObject(req.Where); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
+			return
+		}
+	}
+	if req.Limit != nil && *req.Limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+
+	// The sheet was resolved by SheetAuthMiddleware (api_key or Authorization header)
+	sheet, ok := middleware.SheetFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet not resolved"})
 		return
 	}
 
@@ -44,6 +77,10 @@ func (h *SheetHandler) updateSheetRows(c *gin.Context, method string) {
 		c.JSON(http.StatusForbidden, gin.H{"error": method + " method not enabled for this sheet"})
 		return
 	}
+	if upsert && !isMethodAllowed(sheet.AllowedMethods, "POST") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "upsert requires POST to be enabled for this sheet"})
+		return
+	}
 
 	// Get the user to access their Google tokens
 	user, err := h.userRepo.FindByID(c.Request.Context(), sheet.UserID)
@@ -52,9 +89,6 @@ func (h *SheetHandler) updateSheetRows(c *gin.Context, method string) {
 		return
 	}
 
-	c.Set("sheet_id", sheet.ID)
-	c.Set("user_id", user.ID)
-
 	if user.GoogleAccessToken == nil {
 		c.JSON(401, gin.H{"error": "sheet owner needs to reconnect Google account"})
 		return
@@ -68,83 +102,168 @@ func (h *SheetHandler) updateSheetRows(c *gin.Context, method string) {
 	if targetRange == "" {
 		targetRange = "Sheet1"
 	}
+	if !requireScope(c, models.ScopeWrite, targetRange) {
+		return
+	}
+
+	if upsert {
+		limits := user.GetPlanLimits()
+		if bulk && tooManyBulkRows(c, limits, len(inputs)) {
+			return
+		}
+		if bulk && exceedsQuota(c, limits.GetBulkWriteUnits(len(inputs))) {
+			return
+		}
+		h.upsertRows(c, sheet, *user.GoogleAccessToken, targetRange, inputs, bulk, req.OnConflict, req.Returning, limits)
+		return
+	}
+
+	// Headerless sheets key where and data by column letter or index
+	useHeader := sheet.UseFirstRowAsHeader
+	input := inputs[0]
+	if !useHeader {
+		if err := positionalFilter(filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid where filter", "details": err.Error()})
+			return
+		}
+		if input, err = positionalData(input); err != nil {
+			validationFailed(c, err)
+			return
+		}
+	}
+
+	// Validate values against the declared column types
+	problems := &validationError{}
+	data, err := prepareWriteData(input, sheet.ColumnSchema)
+	if err != nil {
+		problems.merge(err)
+	}
 
 	// Fetch current sheet data to get headers and rows
 	sheetData, err := h.fetchSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange)
-	if err != nil || len(sheetData) == 0 {
+	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to fetch sheet data", "details": err.Error()})
 		return
 	}
-	headers := sheetData[0]
-	rows := transformToJSON(sheetData)
+	if len(sheetData) == 0 {
+		// An empty tab has no rows to match
+		c.JSON(404, gin.H{"error": "no rows matched for update"})
+		return
+	}
+	headerRows := headerRowCount(useHeader)
+	rows := sheetRows(sheetData, useHeader)
+	schema := resolveColumnSchema(sheet.ColumnSchema, rows)
+	rows = coerceRows(rows, schema)
 
-	// Find rows matching 'where' (if provided)
-	var updatedRows []map[string]interface{}
-	match := false
-	rowIndex := -1
+	var headers []interface{}
+	if useHeader {
+		headers = sheetData[0]
+	} else {
+		headers = columnHeaders(len(mapRawRow(data, rawWidth(sheetData))))
+	}
+	if useHeader && sheet.StrictFields {
+		checkUnknownFields(problems, input, headers)
+	}
+	if err := problems.err(); err != nil {
+		validationFailed(c, err)
+		return
+	}
+	data = inputData(data, sheet.InputMode())
+
+	sheetName, _, _ := parseRange(targetRange)
+	firstRow := rangeStartRow(targetRange) + headerRows
+	startColumn := rangeStartColumn(targetRange)
+
+	// Apply the changes to every matching row, writing only the cells that change.
+	// With If-Match every matched row must still carry one of the given versions.
+	precondition := parseIfMatch(c)
+	generator := newRowGenerator(sheet.ColumnSchema, rows, h.sequence(c.Request.Context(), sheet, targetRange))
+	var conflicts []gin.H
+	var newRows [][]interface{}
+	var writes []cellWrite
+	var ranges []*sheets.ValueRange
+	matched := 0
+	updated := 0
 	for i, row := range rows {
-		if req.Where != nil {
-			for k, v := range req.Where {
-				if row[k] == v {
-					match = true
-					rowIndex = i + 1 // header row additional
-					break
-				}
-			}
+		if req.Limit != nil && matched >= *req.Limit {
+			break
 		}
-	}
+		if !filter.matches(row) {
+			continue
+		}
+		matched++
 
-	if match {
-		// Use previous row values for fields not present in req.Data
-		prevRow := sheetData[rowIndex]
-		newRow := make([]interface{}, len(headers))
-		for j, h := range headers {
-			key := h
-			if v, ok := req.Data[fmt.Sprintf("%v", key)]; ok {
-				newRow[j] = v
-			} else {
-				// Use previous value if not updated
-				if j < len(prevRow) {
-					newRow[j] = prevRow[j]
-				} else {
-					newRow[j] = nil
-				}
-			}
+		if version := rowVersion(sheetData[i+headerRows]); !precondition.allows(version) {
+			conflicts = append(conflicts, gin.H{"row": firstRow + i, "version": version})
+			continue
 		}
-		// Update the row in sheetData
-		sheetData[rowIndex] = newRow
 
-		// Prepare response updated rows
-		updatedRow := make(map[string]interface{})
-		for j, h := range headers {
-			updatedRow[fmt.Sprintf("%v", h)] = newRow[j]
+		newRow, changed := mergeRow(headers, sheetData[i+headerRows], data, schema)
+		if changed {
+			// Stamp now_on_update columns and backfill empty generated ones
+			newRow, _ = mergeRow(headers, sheetData[i+headerRows], generator.update(data, row), schema)
+			cellRanges, cellWrites := changedCells(sheetName, startColumn, firstRow+i, len(newRows), headers, sheetData[i+headerRows], newRow, schema)
+			ranges = append(ranges, cellRanges...)
+			writes = append(writes, cellWrites...)
+			updated++
 		}
-		updatedRows = append(updatedRows, updatedRow)
-	} else {
+		newRows = append(newRows, newRow)
+	}
+
+	if generatorFailed(c, generator) {
+		return
+	}
+	if matched == 0 {
 		c.JSON(404, gin.H{"error": "no rows matched for update"})
 		return
 	}
-
-	// Write updated data back to sheet (excluding header row)
-	targetRange = targetRange + "!A2"
-	if err := h.updateSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, targetRange, sheetData[1:]); err != nil {
-		c.JSON(500, gin.H{"error": "failed to update data", "details": err.Error()})
+	if len(conflicts) > 0 {
+		preconditionFailed(c, conflicts)
 		return
 	}
 
-	// If returning fields specified, filter
-	var responseRows []map[string]interface{}
-	if len(req.Returning) > 0 {
-		for _, row := range updatedRows {
-			filtered := make(map[string]interface{})
-			for _, k := range req.Returning {
-				filtered[k] = row[k]
-			}
-			responseRows = append(responseRows, filtered)
+	if len(ranges) > 0 {
+		stored, err := h.batchUpdateSheetData(c.Request.Context(), *user.GoogleAccessToken, sheet.SheetID, ranges, sheet.InputMode())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "failed to update data", "details": err.Error()})
+			return
 		}
-	} else {
-		responseRows = updatedRows
+		// Use the values the sheet stored (formulas evaluated) when available
+		storeWrittenCells(newRows, writes, stored)
+	}
+
+	// If returning fields specified, filter
+	responseRows := make([]map[string]interface{}, 0, len(newRows))
+	for _, values := range newRows {
+		responseRows = append(responseRows, versionedRow(headers, values, schema, req.Returning))
 	}
 
-	c.JSON(200, gin.H{"data": responseRows})
+	setRowVersion(c, responseRows)
+	c.JSON(200, gin.H{"data": responseRows, "matched": matched, "updated": updated})
+}
+
+// columnTypeAt returns the declared type of the column at index j of headers
+func columnTypeAt(headers []interface{}, schema models.ColumnSchema, j int) models.ColumnType {
+	if j >= len(headers) {
+		return ""
+	}
+	return schema[fmt.Sprintf("%v", headers[j])].Type
+}
+
+// sameCell reports whether a new value would leave a cell of the given column
+// type unchanged. Both values are coerced to the type first, so "1,000" and
+// 1000 or "TRUE" and true are the same cell. A leading apostrophe (text
+// forced by the value input mode) is not stored.
+func sameCell(prev, next interface{}, t models.ColumnType) bool {
+	if s, ok := next.(string); ok {
+		next = strings.TrimPrefix(s, "'")
+	}
+	prev, next = coerceValue(prev, t), coerceValue(next, t)
+	if isEmptyValue(prev) && isEmptyValue(next) {
+		return true
+	}
+	if prev == nil || next == nil {
+		return false
+	}
+	return fmt.Sprintf("%v", prev) == fmt.Sprintf("%v", next)
 }
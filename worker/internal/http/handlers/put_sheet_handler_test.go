@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+
+	"gsheetbase/shared/models"
+)
+
+func TestSameCell(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next interface{}
+		columnType models.ColumnType
+		want       bool
+	}{
+		{"grouped number", "1,000", float64(1000), models.ColumnNumber, true},
+		{"trailing zero", "1.50", float64(1.5), models.ColumnNumber, true},
+		{"integer", "7", int64(7), models.ColumnInteger, true},
+		{"changed number", "1,000", float64(1001), models.ColumnNumber, false},
+		{"boolean", "TRUE", true, models.ColumnBoolean, true},
+		{"changed boolean", "TRUE", false, models.ColumnBoolean, false},
+		{"date layouts", "1/2/2025", "2025-01-02", models.ColumnDate, true},
+		{"untyped text", "1.50", float64(1.5), "", false},
+		{"same text", "a", "a", models.ColumnString, true},
+		{"empty and nil", "", nil, models.ColumnNumber, true},
+		{"cleared", "5", "", models.ColumnNumber, false},
+		{"filled", nil, "x", models.ColumnString, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameCell(tt.prev, tt.next, tt.columnType); got != tt.want {
+				t.Errorf("sameCell(%v, %v, %s) = %v, want %v", tt.prev, tt.next, tt.columnType, got, tt.want)
+			}
+		})
+	}
+}
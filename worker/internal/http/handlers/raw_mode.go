@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sheets published with use_first_row_as_header=false have no header row.
+// Every row is data and columns are addressed by their letter (A, B, C...),
+// or by 0-based index where a client passes a column reference.
+
+// columnLetterPattern matches an A1 column reference such as "C" or "AB"
+var columnLetterPattern = regexp.MustCompile(`^[A-Za-z]{1,3}$`)
+
+// headerRowCount returns how many leading rows hold column names
+func headerRowCount(useHeader bool) int {
+	if useHeader {
+		return 1
+	}
+	return 0
+}
+
+// sheetRows converts fetched values to rows keyed by header name, or by
+// column letter when the sheet has no header row
+func sheetRows(data [][]interface{}, useHeader bool) []map[string]interface{} {
+	if useHeader {
+		return transformToJSON(data)
+	}
+	return transformToRaw(data)
+}
+
+// transformToRaw converts a 2D array to rows keyed by column letter.
+// Short rows are padded with nil up to the widest row.
+func transformToRaw(data [][]interface{}) []map[string]interface{} {
+	columns := rawColumns(rawWidth(data))
+	result := make([]map[string]interface{}, 0, len(data))
+	for _, row := range data {
+		obj := make(map[string]interface{}, len(columns))
+		for j, col := range columns {
+			if j < len(row) {
+				obj[col] = row[j]
+			} else {
+				obj[col] = nil
+			}
+		}
+		result = append(result, obj)
+	}
+	return result
+}
+
+// rawWidth returns the number of columns in the widest row
+func rawWidth(data [][]interface{}) int {
+	width := 0
+	for _, row := range data {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	return width
+}
+
+// rawColumns returns the column letters A.. for the given width
+func rawColumns(width int) []string {
+	columns := make([]string, width)
+	for i := range columns {
+		columns[i] = columnLetter(i)
+	}
+	return columns
+}
+
+// columnIndex converts A1 column letters to a 0-indexed column ("A" -> 0, "AA" -> 26)
+func columnIndex(letters string) int {
+	index := 0
+	for _, r := range strings.ToUpper(letters) {
+		index = index*26 + int(r-'A') + 1
+	}
+	return index - 1
+}
+
+// parseColumnRef normalizes a column reference given as letters ("b") or a
+// 0-based index ("1") to upper-case letters ("B")
+func parseColumnRef(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if columnLetterPattern.MatchString(ref) {
+		return strings.ToUpper(ref), nil
+	}
+	if index, err := strconv.Atoi(ref); err == nil && index >= 0 {
+		return columnLetter(index), nil
+	}
+	return "", fmt.Errorf("%q is not a column letter or index", ref)
+}
+
+// positionalFields normalizes a fields list such as "a,2,D" to "A,C,D"
+func positionalFields(fields string) (string, error) {
+	if fields == "" {
+		return "", nil
+	}
+	refs := strings.Split(fields, ",")
+	for i, ref := range refs {
+		col, err := parseColumnRef(ref)
+		if err != nil {
+			return "", err
+		}
+		refs[i] = col
+	}
+	return strings.Join(refs, ","), nil
+}
+
+// positionalFilter rewrites the fields of a where filter to column letters
+func positionalFilter(f *filterNode) error {
+	if f == nil {
+		return nil
+	}
+	for _, child := range append(append([]*filterNode{}, f.and...), f.or...) {
+		if err := positionalFilter(child); err != nil {
+			return err
+		}
+	}
+	if f.field == "" {
+		return nil
+	}
+	col, err := parseColumnRef(f.field)
+	if err != nil {
+		return err
+	}
+	f.field = col
+	return nil
+}
+
+// positionalSortKeys rewrites orderBy fields to column letters
+func positionalSortKeys(keys []sortKey) error {
+	for i := range keys {
+		col, err := parseColumnRef(keys[i].field)
+		if err != nil {
+			return err
+		}
+		keys[i].field = col
+	}
+	return nil
+}
+
+// positionalData rewrites the keys of a write body to column letters
+func positionalData(input map[string]interface{}) (map[string]interface{}, error) {
+	data := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		col, err := parseColumnRef(key)
+		if err != nil {
+			return nil, err
+		}
+		data[col] = value
+	}
+	return data, nil
+}
+
+// mapRawRow lays out a write body keyed by column letter as a sheet row.
+// Columns not present in the body are left empty.
+func mapRawRow(data map[string]interface{}, width int) []interface{} {
+	for col := range data {
+		if index := columnIndex(col); index >= width {
+			width = index + 1
+		}
+	}
+	row := make([]interface{}, width)
+	for col, value := range data {
+		row[columnIndex(col)] = value
+	}
+	return row
+}
+
+// rowsToArrays converts rows to positional arrays in column order
+func rowsToArrays(rows []map[string]interface{}, columns []string) [][]interface{} {
+	result := make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		values := make([]interface{}, len(columns))
+		for i, col := range columns {
+			values[i] = row[col]
+		}
+		result = append(result, values)
+	}
+	return result
+}
+
+// responseData shapes rows for a JSON response: objects for sheets with a
+// header row, positional arrays otherwise
+func responseData(rows []map[string]interface{}, columns []string, useHeader bool) interface{} {
+	if useHeader {
+		return rows
+	}
+	return rowsToArrays(rows, columns)
+}
+
+// columnHeaders returns the column letters of a headerless sheet in the
+// shape of a fetched header row
+func columnHeaders(width int) []interface{} {
+	headers := make([]interface{}, width)
+	for i := range headers {
+		headers[i] = columnLetter(i)
+	}
+	return headers
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		letters string
+		want    int
+	}{
+		{"A", 0},
+		{"b", 1},
+		{"Z", 25},
+		{"AA", 26},
+		{"AZ", 51},
+		{"BA", 52},
+	}
+
+	for _, tt := range tests {
+		if got := columnIndex(tt.letters); got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, want %d", tt.letters, got, tt.want)
+		}
+		if got := columnLetter(tt.want); got != strings.ToUpper(tt.letters) {
+			t.Errorf("columnLetter(%d) = %q, want %q", tt.want, got, strings.ToUpper(tt.letters))
+		}
+	}
+}
+
+func TestParseColumnRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"b", "B", false},
+		{" AB ", "AB", false},
+		{"0", "A", false},
+		{"27", "AB", false},
+		{"-1", "", true},
+		{"name", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := parseColumnRef(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseColumnRef(%q) err = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseColumnRef(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformToRaw(t *testing.T) {
+	data := [][]interface{}{{"a", "b", "c"}, {"d"}}
+	want := []map[string]interface{}{
+		{"A": "a", "B": "b", "C": "c"},
+		{"A": "d", "B": nil, "C": nil},
+	}
+	got := transformToRaw(data)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("transformToRaw = %v, want %v", got, want)
+	}
+	if arrays := rowsToArrays(got, rawColumns(3)); !reflect.DeepEqual(arrays, [][]interface{}{{"a", "b", "c"}, {"d", nil, nil}}) {
+		t.Errorf("rowsToArrays = %v", arrays)
+	}
+}
+
+func TestPositionalWrite(t *testing.T) {
+	data, err := positionalData(map[string]interface{}{"a": "x", "2": "y"})
+	if err != nil {
+		t.Fatalf("positionalData err = %v", err)
+	}
+	if want := map[string]interface{}{"A": "x", "C": "y"}; !reflect.DeepEqual(data, want) {
+		t.Errorf("positionalData = %v, want %v", data, want)
+	}
+	if _, err := positionalData(map[string]interface{}{"name": "x"}); err == nil {
+		t.Error("positionalData accepted a non-column key")
+	}
+
+	tests := []struct {
+		name  string
+		width int
+		want  []interface{}
+	}{
+		{"pads to the sheet width", 4, []interface{}{"x", nil, "y", nil}},
+		{"grows past the sheet width", 2, []interface{}{"x", nil, "y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapRawRow(data, tt.width); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapRawRow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
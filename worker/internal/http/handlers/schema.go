@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"gsheetbase/shared/models"
+)
+
+// schemaInferenceSampleSize is the number of rows inspected when inferring column types
+const schemaInferenceSampleSize = 100
+
+// inferNumberPattern matches plain decimal numbers. Values with a leading zero
+// (zip codes, phone numbers) are deliberately left as strings.
+var inferNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+// resolveColumnSchema returns the sheet's declared schema, or one inferred
+// from the rows when the owner has not configured any column types
+func resolveColumnSchema(declared models.ColumnSchema, rows []map[string]interface{}) models.ColumnSchema {
+	if len(declared) > 0 {
+		return declared
+	}
+	return inferColumnSchema(rows)
+}
+
+// inferColumnSchema guesses a type for each column from a sample of rows.
+// A column gets the first type, in order of strictness, that every filled
+// cell in the sample satisfies; json and list are never inferred.
+func inferColumnSchema(rows []map[string]interface{}) models.ColumnSchema {
+	schema := models.ColumnSchema{}
+	if len(rows) == 0 {
+		return schema
+	}
+
+	sample := rows
+	if len(sample) > schemaInferenceSampleSize {
+		sample = sample[:schemaInferenceSampleSize]
+	}
+
+	candidates := []models.ColumnType{
+		models.ColumnBoolean,
+		models.ColumnInteger,
+		models.ColumnNumber,
+		models.ColumnDate,
+		models.ColumnDatetime,
+	}
+
+	for column := range rows[0] {
+		inferred := models.ColumnString
+		for _, candidate := range candidates {
+			filled := 0
+			fits := true
+			for _, row := range sample {
+				value := row[column]
+				if isEmptyValue(value) {
+					continue
+				}
+				filled++
+				if !looksLikeType(stringValue(value), candidate) {
+					fits = false
+					break
+				}
+			}
+			if filled == 0 {
+				break
+			}
+			if fits {
+				inferred = candidate
+				break
+			}
+		}
+		schema[column] = models.ColumnDef{Type: inferred}
+	}
+
+	return schema
+}
+
+// looksLikeType reports whether a raw cell string is an unambiguous value of the given type
+func looksLikeType(s string, t models.ColumnType) bool {
+	s = strings.TrimSpace(s)
+	switch t {
+	case models.ColumnBoolean:
+		return strings.EqualFold(s, "true") || strings.EqualFold(s, "false")
+	case models.ColumnInteger:
+		return inferNumberPattern.MatchString(s) && !strings.Contains(s, ".")
+	case models.ColumnNumber:
+		return inferNumberPattern.MatchString(s)
+	case models.ColumnDate:
+		_, ok := models.ParseDate(s)
+		return ok
+	case models.ColumnDatetime:
+		_, ok := models.ParseDateTime(s)
+		return ok
+	}
+	return false
+}
+
+// coerceRows converts raw sheet cells into typed values in place
+func coerceRows(rows []map[string]interface{}, schema models.ColumnSchema) []map[string]interface{} {
+	if len(schema) == 0 {
+		return rows
+	}
+	for _, row := range rows {
+		coerceRow(row, schema)
+	}
+	return rows
+}
+
+// coerceRow converts the cells of a single row in place
+func coerceRow(row map[string]interface{}, schema models.ColumnSchema) map[string]interface{} {
+	for column, def := range schema {
+		if value, ok := row[column]; ok {
+			row[column] = coerceValue(value, def.Type)
+		}
+	}
+	return row
+}
+
+// coerceValue converts a cell read from the sheet to the column type.
+// Values that do not fit the type are returned unchanged so reads never fail.
+func coerceValue(value interface{}, t models.ColumnType) interface{} {
+	if t == models.ColumnString || t == "" {
+		return value
+	}
+	if isEmptyValue(value) {
+		return nil
+	}
+
+	switch t {
+	case models.ColumnNumber:
+		if f, ok := models.NumericValue(value); ok {
+			return f
+		}
+	case models.ColumnInteger:
+		if f, ok := models.NumericValue(value); ok && f == math.Trunc(f) {
+			return int64(f)
+		}
+	case models.ColumnBoolean:
+		if b, ok := models.BooleanValue(value); ok {
+			return b
+		}
+	case models.ColumnDate:
+		if d, ok := models.DateValue(value); ok {
+			return d.Format("2006-01-02")
+		}
+	case models.ColumnDatetime:
+		if d, ok := models.DateValue(value); ok {
+			return d.Format(time.RFC3339)
+		}
+	case models.ColumnJSON:
+		if s, ok := value.(string); ok {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(s), &decoded); err == nil {
+				return decoded
+			}
+		}
+	case models.ColumnList:
+		if list, ok := value.([]interface{}); ok {
+			return list
+		}
+		return splitList(stringValue(value))
+	}
+	return value
+}
+
+// prepareWriteData validates client data against the declared schema and
+// returns a copy with values converted for the sheet. Columns without a
+// declared type are passed through unchanged, as are values that fail
+// validation (reported as a *validationError).
+func prepareWriteData(data map[string]interface{}, schema models.ColumnSchema) (map[string]interface{}, error) {
+	prepared := make(map[string]interface{}, len(data))
+	problems := &validationError{}
+
+	for field, value := range data {
+		prepared[field] = value
+		def, declared := schema[field]
+		if !declared {
+			continue
+		}
+		converted, err := def.Type.CellValue(value)
+		if err != nil {
+			problems.add(field, fieldInvalidType, err.Error())
+			continue
+		}
+		prepared[field] = converted
+	}
+
+	return prepared, problems.err()
+}
+
+// splitList splits a comma-separated cell into trimmed, non-empty items
+func splitList(s string) []interface{} {
+	items := make([]interface{}, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
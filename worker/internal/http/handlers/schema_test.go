@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"gsheetbase/shared/models"
+)
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      interface{}
+		columnType models.ColumnType
+		want       interface{}
+	}{
+		{"number", "12.5", models.ColumnNumber, float64(12.5)},
+		{"integer", "7", models.ColumnInteger, int64(7)},
+		{"fraction is not an integer", "7.5", models.ColumnInteger, "7.5"},
+		{"boolean", "TRUE", models.ColumnBoolean, true},
+		{"date", "1/2/2025", models.ColumnDate, "2025-01-02"},
+		{"datetime", "2025-01-02 18:30", models.ColumnDatetime, "2025-01-02T18:30:00Z"},
+		{"json", `{"a":1}`, models.ColumnJSON, map[string]interface{}{"a": float64(1)}},
+		{"list", "a, b", models.ColumnList, []interface{}{"a", "b"}},
+		{"empty", "", models.ColumnNumber, nil},
+		{"not a number", "n/a", models.ColumnNumber, "n/a"},
+		{"string", "007", models.ColumnString, "007"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coerceValue(tt.value, tt.columnType); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("coerceValue(%v, %s) = %#v, want %#v", tt.value, tt.columnType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferColumnSchema(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": "1", "price": "9.99", "active": "TRUE", "zip": "02134", "day": "2025-01-02", "note": "x"},
+		{"id": "2", "price": "10", "active": "FALSE", "zip": "10001", "day": "", "note": "3"},
+	}
+	want := map[string]models.ColumnType{
+		"id":     models.ColumnInteger,
+		"price":  models.ColumnNumber,
+		"active": models.ColumnBoolean,
+		"zip":    models.ColumnString,
+		"day":    models.ColumnDate,
+		"note":   models.ColumnString,
+	}
+
+	schema := inferColumnSchema(rows)
+	for column, wantType := range want {
+		if got := schema[column].Type; got != wantType {
+			t.Errorf("%s inferred as %q, want %q", column, got, wantType)
+		}
+	}
+
+	declared := models.ColumnSchema{"id": {Type: models.ColumnString}}
+	if got := resolveColumnSchema(declared, rows); !reflect.DeepEqual(got, declared) {
+		t.Errorf("resolveColumnSchema = %v, want the declared schema", got)
+	}
+}
+
+func TestPrepareWriteData(t *testing.T) {
+	schema := models.ColumnSchema{
+		"qty":    {Type: models.ColumnInteger},
+		"active": {Type: models.ColumnBoolean},
+		"tags":   {Type: models.ColumnList},
+	}
+
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "converts typed columns",
+			data: map[string]interface{}{"qty": "3", "active": "yes", "tags": []interface{}{"a", "b"}, "other": "x"},
+			want: map[string]interface{}{"qty": int64(3), "active": true, "tags": "a, b", "other": "x"},
+		},
+		{name: "rejects a fraction for an integer", data: map[string]interface{}{"qty": 1.5}, wantErr: true},
+		{name: "rejects text for a boolean", data: map[string]interface{}{"active": "maybe"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prepareWriteData(tt.data, schema)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prepareWriteData = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
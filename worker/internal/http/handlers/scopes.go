@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"gsheetbase/worker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireScope answers 403 unless the named API key used for the request, if
+// any, grants scope on the collection of targetRange. The sheet's own api_key
+// and credentials are not scoped.
+func requireScope(c *gin.Context, scope, targetRange string) bool {
+	key, ok := middleware.APIKeyFromContext(c)
+	if !ok {
+		return true
+	}
+	collection := collectionName(targetRange)
+	if key.Allows(scope, collection) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("api key lacks the %s scope for collection %q", scope, collection)})
+	return false
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// searchScoreKey holds the relevance score of a row matched by q=.
+// It is only kept in the response when score=1 is passed.
+const searchScoreKey = "_score"
+
+// searchHighlightKey holds the matched character spans per field (highlight=1)
+const searchHighlightKey = "_highlight"
+
+// searchQuery is a parsed q= parameter: folded tokens that must all match
+type searchQuery struct {
+	tokens []string
+}
+
+// foldedText is a lower-cased, accent-stripped copy of a string. offsets maps
+// each byte of text back to the character index it came from in the original.
+type foldedText struct {
+	text    string
+	offsets []int
+}
+
+// searchWord is a run of letters and digits in a folded text
+type searchWord struct {
+	start, end int // byte offsets into foldedText.text
+}
+
+// parseSearch tokenizes q; a query without any letters or digits yields nil
+func parseSearch(q string) *searchQuery {
+	folded := foldText(q)
+	var tokens []string
+	for _, w := range searchWords(folded.text) {
+		tokens = append(tokens, folded.text[w.start:w.end])
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	return &searchQuery{tokens: tokens}
+}
+
+// foldText lower-cases s and strips accents so "Crème" and "creme" compare equal
+func foldText(s string) foldedText {
+	var b strings.Builder
+	offsets := make([]int, 0, len(s))
+	i := 0
+	for _, r := range s {
+		for _, fr := range norm.NFD.String(string(r)) {
+			if unicode.Is(unicode.Mn, fr) {
+				continue
+			}
+			fr = unicode.ToLower(fr)
+			b.WriteRune(fr)
+			for n := utf8.RuneLen(fr); n > 0; n-- {
+				offsets = append(offsets, i)
+			}
+		}
+		i++
+	}
+	return foldedText{text: b.String(), offsets: offsets}
+}
+
+// searchWords splits a folded text into words of letters and digits
+func searchWords(text string) []searchWord {
+	var words []searchWord
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWordRune && start < 0 {
+			start = i
+		} else if !isWordRune && start >= 0 {
+			words = append(words, searchWord{start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, searchWord{start: start, end: len(text)})
+	}
+	return words
+}
+
+// matchToken scores one query token against a folded cell: 2 per word equal to
+// the token, 1 per word starting with it. spans are character offsets
+// [start, end) of the matched text in the original cell.
+func matchToken(cell foldedText, words []searchWord, token string) (float64, [][2]int) {
+	var score float64
+	var spans [][2]int
+	for _, w := range words {
+		word := cell.text[w.start:w.end]
+		if !strings.HasPrefix(word, token) {
+			continue
+		}
+		if len(word) == len(token) {
+			score += 2
+		} else {
+			score++
+		}
+		end := w.start + len(token)
+		spans = append(spans, [2]int{cell.offsets[w.start], cell.offsets[end-1] + 1})
+	}
+	return score, spans
+}
+
+// searchColumns returns the configured search columns, or every column of the rows
+func searchColumns(configured []string, rows []map[string]interface{}) []string {
+	if len(configured) > 0 {
+		return configured
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	var columns []string
+	for k := range rows[0] {
+		if k != rowNumberKey && k != searchScoreKey && k != rowVersionKey {
+			columns = append(columns, k)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// searchRows keeps the rows in which every query token matches a word in at
+// least one of the columns, recording each row's relevance score
+func searchRows(rows []map[string]interface{}, q *searchQuery, columns []string) []map[string]interface{} {
+	if q == nil {
+		return rows
+	}
+	result := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]foldedText, len(columns))
+		words := make([][]searchWord, len(columns))
+		for i, col := range columns {
+			cells[i] = foldText(exportCell(row[col]))
+			words[i] = searchWords(cells[i].text)
+		}
+
+		var total float64
+		matched := true
+		for _, token := range q.tokens {
+			var tokenScore float64
+			for i := range columns {
+				s, _ := matchToken(cells[i], words[i], token)
+				tokenScore += s
+			}
+			if tokenScore == 0 {
+				matched = false
+				break
+			}
+			total += tokenScore
+		}
+		if matched {
+			row[searchScoreKey] = total
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// finishSearch adds highlight spans for the searched columns present in each
+// row when requested, and drops the score unless it was asked for
+func finishSearch(rows []map[string]interface{}, q *searchQuery, columns []string, withScore, withHighlight bool) {
+	if q == nil {
+		return
+	}
+	for _, row := range rows {
+		if !withScore {
+			delete(row, searchScoreKey)
+		}
+		if !withHighlight {
+			continue
+		}
+		highlight := make(map[string][][2]int)
+		for _, col := range columns {
+			value, ok := row[col]
+			if !ok {
+				continue
+			}
+			cell := foldText(exportCell(value))
+			words := searchWords(cell.text)
+			var spans [][2]int
+			for _, token := range q.tokens {
+				_, s := matchToken(cell, words, token)
+				spans = append(spans, s...)
+			}
+			if len(spans) > 0 {
+				highlight[col] = mergeSpans(spans)
+			}
+		}
+		row[searchHighlightKey] = highlight
+	}
+}
+
+// mergeSpans sorts spans and joins the ones that overlap
+func mergeSpans(spans [][2]int) [][2]int {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := [][2]int{spans[0]}
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if span[0] <= last[1] {
+			if span[1] > last[1] {
+				last[1] = span[1]
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"", nil},
+		{"  -- !", nil},
+		{"Crème Brûlée", []string{"creme", "brulee"}},
+		{"order #42, NEW", []string{"order", "42", "new"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			got := parseSearch(tt.q)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("parseSearch(%q) = %v, want nil", tt.q, got.tokens)
+				}
+				return
+			}
+			if got == nil || !reflect.DeepEqual(got.tokens, tt.want) {
+				t.Errorf("parseSearch(%q) = %v, want %v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		cell      string
+		token     string
+		wantScore float64
+		wantSpans [][2]int
+	}{
+		{"no match", "apple pie", "cake", 0, nil},
+		{"exact word", "apple pie", "pie", 2, [][2]int{{6, 9}}},
+		{"prefix", "apple pie", "app", 1, [][2]int{{0, 3}}},
+		{"inside a word does not match", "pineapple", "apple", 0, nil},
+		{"several words", "pie, pies", "pie", 3, [][2]int{{0, 3}, {5, 8}}},
+		{"accents map to original characters", "Crème brûlée", "bru", 1, [][2]int{{6, 9}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cell := foldText(tt.cell)
+			score, spans := matchToken(cell, searchWords(cell.text), tt.token)
+			if score != tt.wantScore {
+				t.Errorf("score = %v, want %v", score, tt.wantScore)
+			}
+			if !reflect.DeepEqual(spans, tt.wantSpans) {
+				t.Errorf("spans = %v, want %v", spans, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestSearchRows(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"name": "Apple pie", "notes": "classic"},
+		{"name": "Apple crumble", "notes": "apple apple"},
+		{"name": "Cherry pie", "notes": ""},
+	}
+
+	tests := []struct {
+		name   string
+		q      string
+		want   []string
+		scores []float64
+	}{
+		{"single token across columns", "apple", []string{"Apple pie", "Apple crumble"}, []float64{2, 6}},
+		{"every token must match", "apple pie", []string{"Apple pie"}, []float64{4}},
+		{"prefix", "cher", []string{"Cherry pie"}, []float64{1}},
+		{"no match", "banana", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := make([]map[string]interface{}, len(rows))
+			for i, row := range rows {
+				input[i] = make(map[string]interface{}, len(row))
+				for k, v := range row {
+					input[i][k] = v
+				}
+			}
+			var got []string
+			var scores []float64
+			for _, row := range searchRows(input, parseSearch(tt.q), []string{"name", "notes"}) {
+				got = append(got, row["name"].(string))
+				scores = append(scores, row[searchScoreKey].(float64))
+			}
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(scores, tt.scores) {
+				t.Errorf("searchRows(%q) = %v %v, want %v %v", tt.q, got, scores, tt.want, tt.scores)
+			}
+		})
+	}
+}
+
+func TestFinishSearchHighlight(t *testing.T) {
+	row := map[string]interface{}{"name": "Apple applesauce", "notes": "none", searchScoreKey: 3.0}
+	finishSearch([]map[string]interface{}{row}, parseSearch("apple app"), []string{"name", "notes"}, false, true)
+
+	if _, ok := row[searchScoreKey]; ok {
+		t.Error("score kept without score=1")
+	}
+	want := map[string][][2]int{"name": {{0, 5}, {6, 11}}}
+	if got := row[searchHighlightKey]; !reflect.DeepEqual(got, want) {
+		t.Errorf("highlight = %v, want %v", got, want)
+	}
+}
+
+func TestMergeSpans(t *testing.T) {
+	tests := []struct {
+		spans [][2]int
+		want  [][2]int
+	}{
+		{[][2]int{{0, 3}}, [][2]int{{0, 3}}},
+		{[][2]int{{6, 9}, {0, 3}}, [][2]int{{0, 3}, {6, 9}}},
+		{[][2]int{{0, 5}, {0, 3}}, [][2]int{{0, 5}}},
+		{[][2]int{{0, 3}, {3, 6}}, [][2]int{{0, 6}}},
+		{[][2]int{{0, 4}, {2, 8}, {10, 12}}, [][2]int{{0, 8}, {10, 12}}},
+	}
+
+	for _, tt := range tests {
+		if got := mergeSpans(tt.spans); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mergeSpans(%v) = %v, want %v", tt.spans, got, tt.want)
+		}
+	}
+}
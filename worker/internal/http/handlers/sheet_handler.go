@@ -7,9 +7,9 @@ import (
 	"strconv"
 	"strings"
 
+	"gsheetbase/shared/models"
 	"gsheetbase/shared/repository"
 
-	"encoding/json"
 	"sort"
 
 	"golang.org/x/oauth2"
@@ -19,17 +19,34 @@ import (
 )
 
 type SheetHandler struct {
-	sheetRepo repository.AllowedSheetRepo
-	userRepo  repository.UserRepo
+	sheetRepo    repository.AllowedSheetRepo
+	userRepo     repository.UserRepo
+	sequenceRepo repository.SequenceRepo
 }
 
-func NewSheetHandler(sheetRepo repository.AllowedSheetRepo, userRepo repository.UserRepo) *SheetHandler {
+func NewSheetHandler(sheetRepo repository.AllowedSheetRepo, userRepo repository.UserRepo, sequenceRepo repository.SequenceRepo) *SheetHandler {
 	return &SheetHandler{
-		sheetRepo: sheetRepo,
-		userRepo:  userRepo,
+		sheetRepo:    sheetRepo,
+		userRepo:     userRepo,
+		sequenceRepo: sequenceRepo,
 	}
 }
 
+// sequence returns the auto-increment sequence shared by every write to the
+// collection of targetRange
+func (h *SheetHandler) sequence(ctx context.Context, sheet models.AllowedSheet, targetRange string) idSequence {
+	collection := collectionName(targetRange)
+	return func(column string, floor int64, n int) (int64, error) {
+		return h.sequenceRepo.Reserve(ctx, sheet.ID, collection, column, floor, n)
+	}
+}
+
+// collectionName returns the tab name of a range such as 'My Sheet'!A1:C10
+func collectionName(targetRange string) string {
+	name, _, _ := strings.Cut(targetRange, "!")
+	return strings.Trim(name, "'")
+}
+
 // isMethodAllowed checks if a specific HTTP method is allowed for the sheet
 func isMethodAllowed(allowedMethods []string, method string) bool {
 	for _, m := range allowedMethods {
@@ -40,10 +57,11 @@ func isMethodAllowed(allowedMethods []string, method string) bool {
 	return false
 }
 
-// checks if the JSON has the required headers and returns the row slice
-func validateAndMap(headers []interface{}, jsonInput map[string]interface{}) ([]interface{}, error) {
+// validateAndMap lays the JSON out as a row in header order. Required columns
+// must be present; optional ones are left empty.
+func validateAndMap(headers []interface{}, jsonInput map[string]interface{}, schema models.ColumnSchema) ([]interface{}, error) {
 	newRow := make([]interface{}, len(headers))
-	missingHeaders := []string{}
+	problems := &validationError{}
 
 	for i, h := range headers {
 		headerStr, ok := h.(string)
@@ -53,46 +71,18 @@ func validateAndMap(headers []interface{}, jsonInput map[string]interface{}) ([]
 
 		if val, exists := jsonInput[headerStr]; exists {
 			newRow[i] = val
-		} else {
-			missingHeaders = append(missingHeaders, headerStr)
-			newRow[i] = nil
+		} else if isRequiredColumn(schema, headerStr) {
+			problems.add(headerStr, fieldRequired, "is required")
 		}
 	}
 
-	if len(missingHeaders) > 0 {
-		return nil, fmt.Errorf("JSON is missing required fields: %s", strings.Join(missingHeaders, ", "))
+	if err := problems.err(); err != nil {
+		return nil, err
 	}
 
 	return newRow, nil
 }
 
-// Helper: filterRows applies simple equality filter from where JSON
-func filterRows(rows []map[string]interface{}, where string) []map[string]interface{} {
-	if where == "" {
-		return rows
-	}
-	// Parse where as map[string]interface{}
-	var cond map[string]interface{}
-	err := json.Unmarshal([]byte(where), &cond)
-	if err != nil {
-		return rows // ignore invalid filter
-	}
-	filtered := make([]map[string]interface{}, 0)
-	for _, row := range rows {
-		match := true
-		for k, v := range cond {
-			if fmt.Sprintf("%v", row[k]) != fmt.Sprintf("%v", v) {
-				match = false
-				break
-			}
-		}
-		if match {
-			filtered = append(filtered, row)
-		}
-	}
-	return filtered
-}
-
 // Helper: selectFields returns only requested fields
 func selectFields(rows []map[string]interface{}, fields string) []map[string]interface{} {
 	if fields == "" {
@@ -106,22 +96,106 @@ func selectFields(rows []map[string]interface{}, fields string) []map[string]int
 			k = strings.TrimSpace(k)
 			obj[k] = row[k]
 		}
+		// Carry internal keys through; they are stripped before responding
+		for _, k := range []string{rowNumberKey, searchScoreKey, rowVersionKey} {
+			if v, ok := row[k]; ok {
+				obj[k] = v
+			}
+		}
 		selected = append(selected, obj)
 	}
 	return selected
 }
 
-// Helper: orderRows sorts by a single field
-func orderRows(rows []map[string]interface{}, orderBy string) []map[string]interface{} {
-	if orderBy == "" {
+// sortKey is one field of an orderBy expression
+type sortKey struct {
+	field      string
+	descending bool
+}
+
+// parseOrderBy parses "-price,name" into sort keys; a leading "-" sorts descending
+func parseOrderBy(orderBy string) ([]sortKey, error) {
+	if strings.TrimSpace(orderBy) == "" {
+		return nil, nil
+	}
+	var keys []sortKey
+	for _, part := range strings.Split(orderBy, ",") {
+		part = strings.TrimSpace(part)
+		key := sortKey{field: part}
+		if strings.HasPrefix(part, "-") {
+			key = sortKey{field: strings.TrimSpace(part[1:]), descending: true}
+		} else if strings.HasPrefix(part, "+") {
+			key.field = strings.TrimSpace(part[1:])
+		}
+		if key.field == "" {
+			return nil, fmt.Errorf("orderBy contains an empty field name")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Helper: orderRows sorts by each key in turn using the column types.
+// Empty cells always sort last, regardless of direction.
+func orderRows(rows []map[string]interface{}, keys []sortKey, schema models.ColumnSchema) []map[string]interface{} {
+	if len(keys) == 0 {
 		return rows
 	}
 	sort.SliceStable(rows, func(i, j int) bool {
-		return fmt.Sprintf("%v", rows[i][orderBy]) < fmt.Sprintf("%v", rows[j][orderBy])
+		return compareRows(rows[i], rows[j], keys, schema) < 0
 	})
 	return rows
 }
 
+// compareRows orders two rows by the sort keys, returning -1, 0 or 1
+func compareRows(a, b map[string]interface{}, keys []sortKey, schema models.ColumnSchema) int {
+	for _, key := range keys {
+		av, bv := a[key.field], b[key.field]
+		aEmpty, bEmpty := isEmptyValue(av), isEmptyValue(bv)
+		if aEmpty || bEmpty {
+			if aEmpty == bEmpty {
+				continue
+			}
+			if aEmpty {
+				return 1
+			}
+			return -1
+		}
+
+		cmp := compareTyped(av, bv, schema[key.field].Type)
+		if cmp == 0 {
+			continue
+		}
+		if key.descending {
+			return -cmp
+		}
+		return cmp
+	}
+	return 0
+}
+
+// compareTyped compares two non-empty cells according to the column type
+func compareTyped(a, b interface{}, t models.ColumnType) int {
+	switch t {
+	case models.ColumnBoolean:
+		ab, aOk := models.BooleanValue(a)
+		bb, bOk := models.BooleanValue(b)
+		if aOk && bOk {
+			switch {
+			case ab == bb:
+				return 0
+			case !ab:
+				return -1
+			default:
+				return 1
+			}
+		}
+	case models.ColumnString:
+		return strings.Compare(stringValue(a), stringValue(b))
+	}
+	return compareValues(a, b)
+}
+
 // Helper: paginateRows returns paginated slice and pagination info
 func paginateRows(rows []map[string]interface{}, limitStr, offsetStr string) ([]map[string]interface{}, map[string]interface{}) {
 	limit, err1 := strconv.Atoi(limitStr)
@@ -145,6 +219,7 @@ func paginateRows(rows []map[string]interface{}, limitStr, offsetStr string) ([]
 		"limit":      limit,
 		"offset":     offset,
 		"nextOffset": end,
+		"has_more":   end < total,
 	}
 	return paginated, pagination
 }
@@ -194,7 +269,7 @@ func transformToJSON(data [][]interface{}) []map[string]interface{} {
 	return result
 }
 
-func (h *SheetHandler) appendSheetData(ctx context.Context, accessToken, sheetID, rangeStr string, data [][]interface{}) (*sheets.AppendValuesResponse, error) {
+func (h *SheetHandler) appendSheetData(ctx context.Context, accessToken, sheetID, rangeStr string, data [][]interface{}, inputMode string) (*sheets.AppendValuesResponse, error) {
 	srv, err := getSheetsService(ctx, accessToken)
 	if err != nil {
 		return nil, err
@@ -205,7 +280,7 @@ func (h *SheetHandler) appendSheetData(ctx context.Context, accessToken, sheetID
 	}
 
 	resp, err := srv.Spreadsheets.Values.Append(sheetID, rangeStr, valueRange).
-		ValueInputOption("USER_ENTERED").
+		ValueInputOption(valueInputOption(inputMode)).
 		InsertDataOption("INSERT_ROWS").
 		IncludeValuesInResponse(true).
 		Do()
@@ -216,66 +291,54 @@ func (h *SheetHandler) appendSheetData(ctx context.Context, accessToken, sheetID
 	return resp, nil
 }
 
-func (h *SheetHandler) updateSheetData(ctx context.Context, accessToken, sheetID, rangeStr string, data [][]interface{}) error {
+// batchUpdateSheetData writes several single-row ranges in one Values.BatchUpdate
+// call and returns the first row of values the sheet stored for each range
+func (h *SheetHandler) batchUpdateSheetData(ctx context.Context, accessToken, sheetID string, data []*sheets.ValueRange, inputMode string) ([][]interface{}, error) {
 	srv, err := getSheetsService(ctx, accessToken)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	valueRange := &sheets.ValueRange{
-		Values: data,
+	req := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption:        valueInputOption(inputMode),
+		Data:                    data,
+		IncludeValuesInResponse: true,
 	}
 
-	_, err = srv.Spreadsheets.Values.Update(sheetID, rangeStr, valueRange).
-		ValueInputOption("USER_ENTERED").
-		Do()
+	resp, err := srv.Spreadsheets.Values.BatchUpdate(sheetID, req).Do()
 	if err != nil {
-		return fmt.Errorf("unable to update sheet data: %w", err)
+		return nil, fmt.Errorf("unable to update sheet data: %w", err)
 	}
 
-	return nil
+	// Values as stored in the sheet, in the order of data
+	written := make([][]interface{}, len(data))
+	for i, r := range resp.Responses {
+		if i < len(written) && r.UpdatedData != nil && len(r.UpdatedData.Values) > 0 {
+			written[i] = r.UpdatedData.Values[0]
+		}
+	}
+	return written, nil
 }
 
-func (h *SheetHandler) deleteSheetDataAtRowIndex(ctx context.Context, accessToken, spreadsheetId, rangeStr string, rowIndexToDelete int64) error {
+// deleteSheetRows removes the rows at the given 0-based sheet indexes with one
+// BatchUpdate. Requests run bottom to top so earlier deletes don't shift later ones.
+func (h *SheetHandler) deleteSheetRows(ctx context.Context, accessToken, spreadsheetId, rangeStr string, rowIndexes []int64) error {
 	srv, err := getSheetsService(ctx, accessToken)
 	if err != nil {
 		return err
 	}
 
-	spreadsheet, err := srv.Spreadsheets.Get(spreadsheetId).Do()
+	sheetName, _, err := parseRange(rangeStr)
 	if err != nil {
 		return err
 	}
-	sheetName, _, err := parseRange(rangeStr)
+	sheetID, err := sheetTabID(srv, spreadsheetId, sheetName)
 	if err != nil {
 		return err
 	}
-	var sheetID int64
-	found := false
-	for _, s := range spreadsheet.Sheets {
-		if s.Properties.Title == sheetName {
-			sheetID = s.Properties.SheetId
-			found = true
-			break
-		}
-	}
-	if !found {
-		return fmt.Errorf("sheet named '%s' not found", sheetName)
-	}
 
 	batchUpdate := &sheets.BatchUpdateSpreadsheetRequest{
-		Requests: []*sheets.Request{
-			{
-				DeleteDimension: &sheets.DeleteDimensionRequest{
-					Range: &sheets.DimensionRange{
-						SheetId:    sheetID,
-						Dimension:  "ROWS",
-						StartIndex: rowIndexToDelete,
-						EndIndex:   rowIndexToDelete + 1,
-					},
-				},
-			},
-		},
+		Requests: deleteRowRequests(sheetID, rowIndexes),
 	}
 
 	_, err = srv.Spreadsheets.BatchUpdate(spreadsheetId, batchUpdate).Do()
@@ -287,6 +350,53 @@ func (h *SheetHandler) deleteSheetDataAtRowIndex(ctx context.Context, accessToke
 	return nil
 }
 
+// sheetTabID looks up the numeric id of a tab by its title
+func sheetTabID(srv *sheets.Service, spreadsheetId, sheetName string) (int64, error) {
+	tabs, err := sheetTabIDs(srv, spreadsheetId)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := tabs[sheetName]; ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("sheet named '%s' not found", sheetName)
+}
+
+// sheetTabIDs maps every tab title of a spreadsheet to its numeric sheet ID
+func sheetTabIDs(srv *sheets.Service, spreadsheetId string) (map[string]int64, error) {
+	spreadsheet, err := srv.Spreadsheets.Get(spreadsheetId).Fields("sheets.properties").Do()
+	if err != nil {
+		return nil, err
+	}
+	tabs := make(map[string]int64, len(spreadsheet.Sheets))
+	for _, s := range spreadsheet.Sheets {
+		tabs[s.Properties.Title] = s.Properties.SheetId
+	}
+	return tabs, nil
+}
+
+// deleteRowRequests builds DeleteDimension requests for 0-based row indexes,
+// ordered from the bottom of the sheet to the top
+func deleteRowRequests(sheetID int64, rowIndexes []int64) []*sheets.Request {
+	sorted := append([]int64{}, rowIndexes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+
+	requests := make([]*sheets.Request, 0, len(sorted))
+	for _, index := range sorted {
+		requests = append(requests, &sheets.Request{
+			DeleteDimension: &sheets.DeleteDimensionRequest{
+				Range: &sheets.DimensionRange{
+					SheetId:    sheetID,
+					Dimension:  "ROWS",
+					StartIndex: index,
+					EndIndex:   index + 1,
+				},
+			},
+		})
+	}
+	return requests
+}
+
 // getSheetsService creates a Google Sheets service using the provided access token.
 // In the future, this can be extended to check token expiry and refresh if needed.
 func getSheetsService(ctx context.Context, accessToken string) (*sheets.Service, error) {
@@ -325,3 +435,33 @@ func parseRange(rangeStr string) (string, int64, error) {
 	// Convert to 0-indexed (Row 2 becomes Index 1)
 	return sheetName, rowNum - 1, nil
 }
+
+// rangeStartColumn returns the 0-indexed column a range starts at ("Sheet1!C5:F" → 2)
+func rangeStartColumn(rangeStr string) int {
+	parts := strings.Split(rangeStr, "!")
+	if len(parts) != 2 {
+		return 0
+	}
+	letters := regexp.MustCompile(`^[A-Za-z]+`).FindString(parts[1])
+	if letters == "" {
+		return 0
+	}
+	return columnIndex(letters)
+}
+
+// a1Range builds the A1 range of one row starting at a column, quoting the
+// sheet name ("My Sheet", 2, 5 → "'My Sheet'!C5")
+func a1Range(sheetName string, column, row int) string {
+	quoted := "'" + strings.ReplaceAll(sheetName, "'", "''") + "'"
+	return fmt.Sprintf("%s!%s%d", quoted, columnLetter(column), row)
+}
+
+// columnLetter converts a 0-indexed column to its A1 letters (0 -> "A", 26 -> "AA")
+func columnLetter(index int) string {
+	letters := ""
+	for index >= 0 {
+		letters = string(rune('A'+index%26)) + letters
+		index = index/26 - 1
+	}
+	return letters
+}
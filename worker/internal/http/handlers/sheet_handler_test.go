@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"gsheetbase/shared/models"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		orderBy string
+		want    []sortKey
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"name", []sortKey{{field: "name"}}, false},
+		{"-price, +name", []sortKey{{field: "price", descending: true}, {field: "name"}}, false},
+		{"price,,name", nil, true},
+		{"-", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.orderBy, func(t *testing.T) {
+			got, err := parseOrderBy(tt.orderBy)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseOrderBy(%q) = %v, %v; want %v, error %v", tt.orderBy, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOrderRows(t *testing.T) {
+	schema := models.ColumnSchema{
+		"qty":    {Type: models.ColumnNumber},
+		"code":   {Type: models.ColumnString},
+		"active": {Type: models.ColumnBoolean},
+	}
+	rows := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"id": "a", "qty": float64(10), "code": "10", "active": true},
+			{"id": "b", "qty": float64(9), "code": "9", "active": false},
+			{"id": "c", "qty": nil, "code": "", "active": true},
+			{"id": "d", "qty": float64(10), "code": "2", "active": false},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		orderBy string
+		want    []string
+	}{
+		{"numbers compare as numbers", "qty", []string{"b", "a", "d", "c"}},
+		{"empty cells last when descending", "-qty", []string{"a", "d", "b", "c"}},
+		{"strings compare as text", "code", []string{"a", "d", "b", "c"}},
+		{"second key breaks ties", "-qty,code", []string{"a", "d", "b", "c"}},
+		{"second key descending", "qty,-code", []string{"b", "d", "a", "c"}},
+		{"booleans false first", "active,id", []string{"b", "d", "a", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseOrderBy(tt.orderBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, row := range orderRows(rows(), keys, schema) {
+				got = append(got, row["id"].(string))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteRowRequests(t *testing.T) {
+	requests := deleteRowRequests(7, []int64{3, 9, 1})
+
+	var got []int64
+	for _, r := range requests {
+		d := r.DeleteDimension.Range
+		if d.SheetId != 7 || d.Dimension != "ROWS" || d.EndIndex != d.StartIndex+1 {
+			t.Fatalf("range %+v is not a single row of tab 7", d)
+		}
+		got = append(got, d.StartIndex)
+	}
+	if want := []int64{9, 3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deleted rows = %v, want bottom to top %v", got, want)
+	}
+}
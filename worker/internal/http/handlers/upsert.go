@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/sheets/v4"
+)
+
+// upsertAllowed reports whether a sheet permits both halves of an upsert:
+// inserting (POST) and updating (PUT or PATCH)
+func upsertAllowed(allowedMethods []string) bool {
+	return isMethodAllowed(allowedMethods, "POST") &&
+		(isMethodAllowed(allowedMethods, "PUT") || isMethodAllowed(allowedMethods, "PATCH"))
+}
+
+// upsertKeyColumn picks the column upserts are keyed on: the requested
+// on_conflict column, otherwise the sheet's primary-key column
+func upsertKeyColumn(requested string, sheet models.AllowedSheet) (string, error) {
+	if requested = strings.TrimSpace(requested); requested != "" {
+		return requested, nil
+	}
+	if sheet.PrimaryKeyColumn != nil && *sheet.PrimaryKeyColumn != "" {
+		return *sheet.PrimaryKeyColumn, nil
+	}
+	return "", fmt.Errorf("set on_conflict or configure a primary key column for this sheet")
+}
+
+// upsertKey normalizes a key cell so sheet values and request values compare
+// equal (e.g. "7" in the sheet and 7 in JSON for an integer column)
+func upsertKey(value interface{}, t models.ColumnType) string {
+	return strings.TrimSpace(exportCell(coerceValue(value, t)))
+}
+
+// mergeRow overlays data onto an existing sheet row, reporting whether any
+// cell changes once compared as its column type
+func mergeRow(headers []interface{}, prevRow []interface{}, data map[string]interface{}, schema models.ColumnSchema) ([]interface{}, bool) {
+	newRow := make([]interface{}, len(headers))
+	changed := false
+	for j, h := range headers {
+		var prev interface{}
+		if j < len(prevRow) {
+			prev = prevRow[j]
+		}
+		newRow[j] = prev
+		if v, ok := data[fmt.Sprintf("%v", h)]; ok {
+			newRow[j] = v
+			if !sameCell(prev, v, schema[fmt.Sprintf("%v", h)].Type) {
+				changed = true
+			}
+		}
+	}
+	return newRow, changed
+}
+
+// cellWrite locates a cell written by changedCells: the result row it belongs
+// to and its column
+type cellWrite struct {
+	row    int
+	column int
+}
+
+// changedCells returns a single-cell range for each cell that differs between
+// prev and next, at sheet row number rowNumber. Only these cells are written:
+// the row's untouched cells, read back as formatted values, are never sent
+// again, so escaped text cannot turn into a formula and the owner's formulas
+// are kept. Cleared cells are written as "" (Sheets skips null values).
+func changedCells(sheetName string, startColumn, rowNumber, resultRow int, headers, prev, next []interface{}, schema models.ColumnSchema) ([]*sheets.ValueRange, []cellWrite) {
+	var ranges []*sheets.ValueRange
+	var writes []cellWrite
+	for j, v := range next {
+		var p interface{}
+		if j < len(prev) {
+			p = prev[j]
+		}
+		if sameCell(p, v, columnTypeAt(headers, schema, j)) {
+			continue
+		}
+		if v == nil {
+			v = ""
+		}
+		ranges = append(ranges, &sheets.ValueRange{
+			Range:  a1Range(sheetName, startColumn+j, rowNumber),
+			Values: [][]interface{}{{v}},
+		})
+		writes = append(writes, cellWrite{row: resultRow, column: j})
+	}
+	return ranges, writes
+}
+
+// storeWrittenCells replaces the written cells of the result rows with the
+// values the sheet stored for them (formulas evaluated), when available
+func storeWrittenCells(results [][]interface{}, writes []cellWrite, stored [][]interface{}) {
+	for n, w := range writes {
+		if n < len(stored) && len(stored[n]) > 0 && w.column < len(results[w.row]) {
+			results[w.row][w.column] = stored[n][0]
+		}
+	}
+}
+
+// upsertRows updates the row whose keyColumn matches each input and appends
+// the inputs that match nothing. Updates are merged into the existing row, so
+// they may be partial; inserts must be complete rows. Rows are validated
+// before anything is written, and results are reported in input order; a
+// matched row whose values would not change is reported as "unchanged".
+func (h *SheetHandler) upsertRows(c *gin.Context, sheet models.AllowedSheet, accessToken, targetRange string, inputs []map[string]interface{}, bulk bool, keyColumn string, returning []string, limits models.PlanLimits) {
+	ctx := c.Request.Context()
+	useHeader := sheet.UseFirstRowAsHeader
+
+	// Headerless sheets key the body and the key column by column letter or index
+	if !useHeader {
+		col, err := parseColumnRef(keyColumn)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid on_conflict column", "details": err.Error()})
+			return
+		}
+		keyColumn = col
+		for i, input := range inputs {
+			data, err := positionalData(input)
+			if err != nil {
+				rowsFailed(c, []gin.H{rowError(i, err)}, bulk)
+				return
+			}
+			inputs[i] = data
+		}
+	}
+
+	sheetData, err := h.fetchSheetData(ctx, accessToken, sheet.SheetID, targetRange)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet data", "details": err.Error()})
+		return
+	}
+	if useHeader && len(sheetData) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sheet headers"})
+		return
+	}
+	headerRows := headerRowCount(useHeader)
+	rows := sheetRows(sheetData, useHeader)
+	schema := resolveColumnSchema(sheet.ColumnSchema, rows)
+	rows = coerceRows(rows, schema)
+
+	var headers []interface{}
+	if useHeader {
+		headers = sheetData[0]
+		if !containsHeader(headers, keyColumn) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid on_conflict column", "details": fmt.Sprintf("%q is not a column of this sheet", keyColumn)})
+			return
+		}
+	} else {
+		width := rawWidth(sheetData)
+		for _, input := range inputs {
+			if n := len(mapRawRow(input, width)); n > width {
+				width = n
+			}
+		}
+		headers = columnHeaders(width)
+	}
+
+	// Index existing rows by key; the first occurrence wins
+	keyType := schema[keyColumn].Type
+	existing := make(map[string]int)
+	for i, row := range rows {
+		if key := upsertKey(row[keyColumn], keyType); key != "" {
+			if _, seen := existing[key]; !seen {
+				existing[key] = i
+			}
+		}
+	}
+
+	sheetName, _, _ := parseRange(targetRange)
+	firstRow := rangeStartRow(targetRange) + headerRows
+	startColumn := rangeStartColumn(targetRange)
+
+	// With If-Match every row being updated must still carry one of the given
+	// versions, and nothing may be inserted (even "*" requires the row to exist)
+	precondition := parseIfMatch(c)
+	var conflicts []gin.H
+
+	// Inserts get generated columns filled; updates get now_on_update stamped
+	generator := newRowGenerator(sheet.ColumnSchema, rows, h.sequence(ctx, sheet, targetRange))
+
+	actions := make([]string, len(inputs))
+	results := make([][]interface{}, len(inputs))
+	var ranges []*sheets.ValueRange
+	var writes []cellWrite
+	var appendRows [][]interface{}
+	var appendIndexes []int
+	var rowErrors []gin.H
+	seenKeys := make(map[string]int)
+
+	for i, input := range inputs {
+		key := upsertKey(input[keyColumn], keyType)
+		if key == "" {
+			problems := &validationError{}
+			problems.add(keyColumn, fieldRequired, "is required as the upsert key")
+			rowErrors = append(rowErrors, rowError(i, problems.err()))
+			continue
+		}
+		if j, dup := seenKeys[key]; dup {
+			rowErrors = append(rowErrors, gin.H{"index": i, "details": fmt.Sprintf("duplicate key %q (also in row %d)", key, j)})
+			continue
+		}
+		seenKeys[key] = i
+
+		if idx, ok := existing[key]; ok {
+			if version := rowVersion(sheetData[idx+headerRows]); !precondition.allows(version) {
+				conflicts = append(conflicts, gin.H{"index": i, "row": firstRow + idx, "version": version})
+				continue
+			}
+			problems := &validationError{}
+			data, err := prepareWriteData(input, sheet.ColumnSchema)
+			if err != nil {
+				problems.merge(err)
+			}
+			if useHeader && sheet.StrictFields {
+				checkUnknownFields(problems, input, headers)
+			}
+			if err := problems.err(); err != nil {
+				rowErrors = append(rowErrors, rowError(i, err))
+				continue
+			}
+			data = inputData(data, sheet.InputMode())
+			newRow, changed := mergeRow(headers, sheetData[idx+headerRows], data, schema)
+			if changed {
+				newRow, _ = mergeRow(headers, sheetData[idx+headerRows], generator.update(data, rows[idx]), schema)
+				cellRanges, cellWrites := changedCells(sheetName, startColumn, firstRow+idx, i, headers, sheetData[idx+headerRows], newRow, schema)
+				ranges = append(ranges, cellRanges...)
+				writes = append(writes, cellWrites...)
+				actions[i] = "updated"
+			} else {
+				actions[i] = "unchanged"
+			}
+			results[i] = newRow
+			continue
+		}
+
+		if precondition.present {
+			conflicts = append(conflicts, gin.H{"index": i, "version": nil})
+			continue
+		}
+		row, err := mapWriteRow(generator.insert(input), headers, useHeader, sheet.ColumnSchema, sheet.StrictFields)
+		if err != nil {
+			rowErrors = append(rowErrors, rowError(i, err))
+			continue
+		}
+		actions[i] = "inserted"
+		row = inputRow(row, sheet.InputMode())
+		results[i] = row
+		appendRows = append(appendRows, row)
+		appendIndexes = append(appendIndexes, i)
+	}
+
+	if generatorFailed(c, generator) {
+		return
+	}
+	if len(rowErrors) > 0 {
+		rowsFailed(c, rowErrors, bulk)
+		return
+	}
+	if len(conflicts) > 0 {
+		preconditionFailed(c, conflicts)
+		return
+	}
+
+	if len(ranges) > 0 {
+		stored, err := h.batchUpdateSheetData(ctx, accessToken, sheet.SheetID, ranges, sheet.InputMode())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update data", "details": err.Error()})
+			return
+		}
+		storeWrittenCells(results, writes, stored)
+	}
+
+	if len(appendRows) > 0 {
+		appendResp, err := h.appendSheetData(ctx, accessToken, sheet.SheetID, targetRange, appendRows, sheet.InputMode())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to append data", "details": err.Error()})
+			return
+		}
+		// Use the values returned in the response when available
+		if appendResp != nil && appendResp.Updates != nil && appendResp.Updates.UpdatedData != nil && len(appendResp.Updates.UpdatedData.Values) == len(appendRows) {
+			for n, i := range appendIndexes {
+				results[i] = appendResp.Updates.UpdatedData.Values[n]
+			}
+		}
+	}
+
+	data := make([]map[string]interface{}, 0, len(results))
+	for _, values := range results {
+		data = append(data, versionedRow(headers, values, sheet.ColumnSchema, returning))
+	}
+
+	status := http.StatusOK
+	if len(appendRows) > 0 {
+		status = http.StatusCreated
+	}
+
+	if !bulk {
+		setRowVersion(c, data)
+		c.JSON(status, gin.H{"data": data[0], "action": actions[0]})
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, action := range actions {
+		counts[action]++
+	}
+	c.Set("usage_units", limits.GetBulkWriteUnits(len(inputs)))
+	c.JSON(status, gin.H{
+		"data":      data,
+		"actions":   actions,
+		"inserted":  counts["inserted"],
+		"updated":   counts["updated"],
+		"unchanged": counts["unchanged"],
+	})
+}
+
+// containsHeader reports whether a header row has the named column
+func containsHeader(headers []interface{}, name string) bool {
+	for _, h := range headers {
+		if fmt.Sprintf("%v", h) == name {
+			return true
+		}
+	}
+	return false
+}
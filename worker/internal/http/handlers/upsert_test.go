@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"gsheetbase/shared/models"
+)
+
+func TestUpsertKeyColumn(t *testing.T) {
+	pk := "sku"
+	tests := []struct {
+		name      string
+		requested string
+		primary   *string
+		want      string
+		wantErr   bool
+	}{
+		{"on_conflict", " email ", &pk, "email", false},
+		{"primary key", "", &pk, "sku", false},
+		{"neither", "", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := upsertKeyColumn(tt.requested, models.AllowedSheet{PrimaryKeyColumn: tt.primary})
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("upsertKeyColumn = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpsertKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		sheetValue interface{}
+		bodyValue  interface{}
+		columnType models.ColumnType
+	}{
+		{"integer", "7", float64(7), models.ColumnInteger},
+		{"padded text", " A1 ", "A1", models.ColumnString},
+		{"untyped number", "42", "42", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if a, b := upsertKey(tt.sheetValue, tt.columnType), upsertKey(tt.bodyValue, tt.columnType); a != b {
+				t.Errorf("keys %q and %q differ", a, b)
+			}
+		})
+	}
+}
+
+func TestMergeRow(t *testing.T) {
+	headers := []interface{}{"sku", "price", "active"}
+	schema := models.ColumnSchema{"price": {Type: models.ColumnNumber}, "active": {Type: models.ColumnBoolean}}
+	prev := []interface{}{"A1", "1,200.50", "TRUE"}
+
+	tests := []struct {
+		name        string
+		data        map[string]interface{}
+		wantRow     []interface{}
+		wantChanged bool
+	}{
+		{"same values as typed", map[string]interface{}{"price": 1200.5, "active": true}, []interface{}{"A1", 1200.5, true}, false},
+		{"changed price", map[string]interface{}{"price": float64(13)}, []interface{}{"A1", float64(13), "TRUE"}, true},
+		{"unknown column", map[string]interface{}{"other": "x"}, []interface{}{"A1", "1,200.50", "TRUE"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, changed := mergeRow(headers, prev, tt.data, schema)
+			if changed != tt.wantChanged || !reflect.DeepEqual(row, tt.wantRow) {
+				t.Errorf("mergeRow = %v, %v; want %v, %v", row, changed, tt.wantRow, tt.wantChanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Field error codes
+const (
+	fieldRequired    = "required"
+	fieldInvalidType = "invalid_type"
+	fieldUnknown     = "unknown_field"
+)
+
+// fieldError is one problem with one field of a write, shaped so a form can
+// highlight the input
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validationError collects the field errors of one row
+type validationError struct {
+	fields []fieldError
+}
+
+func (e *validationError) Error() string {
+	messages := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return "invalid fields: " + strings.Join(messages, "; ")
+}
+
+// add records a field error
+func (e *validationError) add(field, code, message string) {
+	e.fields = append(e.fields, fieldError{Field: field, Code: code, Message: message})
+}
+
+// merge adds the field errors of err, reporting false if err is another kind of error
+func (e *validationError) merge(err error) bool {
+	var other *validationError
+	if !errors.As(err, &other) {
+		return false
+	}
+	e.fields = append(e.fields, other.fields...)
+	return true
+}
+
+// err returns the collected errors sorted by field, or nil if there are none
+func (e *validationError) err() error {
+	if len(e.fields) == 0 {
+		return nil
+	}
+	sort.SliceStable(e.fields, func(i, j int) bool { return e.fields[i].Field < e.fields[j].Field })
+	return e
+}
+
+// fieldErrors returns the field errors carried by err, if any
+func fieldErrors(err error) []fieldError {
+	var v *validationError
+	if errors.As(err, &v) {
+		return v.fields
+	}
+	return nil
+}
+
+// isRequiredColumn reports whether an insert must send the column. Columns
+// without a declared definition are required.
+func isRequiredColumn(schema models.ColumnSchema, column string) bool {
+	def, declared := schema[column]
+	return !declared || def.IsRequired()
+}
+
+// withDefaults returns the input with declared defaults filled in for absent fields
+func withDefaults(input map[string]interface{}, schema models.ColumnSchema) map[string]interface{} {
+	var row map[string]interface{}
+	for column, def := range schema {
+		if def.Default == nil {
+			continue
+		}
+		if _, sent := input[column]; sent {
+			continue
+		}
+		if row == nil {
+			row = copyRow(input)
+		}
+		row[column] = def.Default
+	}
+	if row == nil {
+		return input
+	}
+	return row
+}
+
+// checkUnknownFields adds an error for every field that is not a sheet column
+func checkUnknownFields(problems *validationError, input map[string]interface{}, headers []interface{}) {
+	for field := range input {
+		if !containsHeader(headers, field) {
+			problems.add(field, fieldUnknown, "is not a column of this sheet")
+		}
+	}
+}
+
+// rowError describes a failed row of a bulk write
+func rowError(index int, err error) gin.H {
+	h := gin.H{"index": index, "details": err.Error()}
+	if fields := fieldErrors(err); fields != nil {
+		h["fields"] = fields
+	}
+	return h
+}
+
+// validationFailed answers 400 for a single-row write that failed validation
+func validationFailed(c *gin.Context, err error) {
+	body := gin.H{"error": "failed to validate data", "details": err.Error()}
+	if fields := fieldErrors(err); fields != nil {
+		body["fields"] = fields
+	}
+	c.JSON(http.StatusBadRequest, body)
+}
+
+// rowsFailed answers 400 for a write whose rows failed validation: the first
+// row's error for a single-row write, or every failed row for a bulk write
+func rowsFailed(c *gin.Context, rowErrors []gin.H, bulk bool) {
+	if !bulk {
+		body := gin.H{"error": "failed to validate data", "details": rowErrors[0]["details"]}
+		if fields, ok := rowErrors[0]["fields"]; ok {
+			body["fields"] = fields
+		}
+		c.JSON(http.StatusBadRequest, body)
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "failed to validate data", "errors": rowErrors})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"gsheetbase/shared/models"
+)
+
+func TestMapWriteRowValidation(t *testing.T) {
+	optional := false
+	headers := []interface{}{"name", "status", "note", "qty"}
+	schema := models.ColumnSchema{
+		"name":   {Type: models.ColumnString},
+		"status": {Type: models.ColumnString, Default: "open"},
+		"note":   {Type: models.ColumnString, Required: &optional},
+		"qty":    {Type: models.ColumnInteger},
+	}
+
+	tests := []struct {
+		name       string
+		input      map[string]interface{}
+		strict     bool
+		wantRow    []interface{}
+		wantFields []fieldError
+	}{
+		{
+			name:    "default fills an absent field",
+			input:   map[string]interface{}{"name": "a", "qty": float64(1)},
+			wantRow: []interface{}{"a", "open", nil, int64(1)},
+		},
+		{
+			name:    "sent value wins over the default",
+			input:   map[string]interface{}{"name": "a", "status": "closed", "note": "n", "qty": float64(1)},
+			wantRow: []interface{}{"a", "closed", "n", int64(1)},
+		},
+		{
+			name:    "unknown field ignored when not strict",
+			input:   map[string]interface{}{"name": "a", "qty": float64(1), "extra": "x"},
+			wantRow: []interface{}{"a", "open", nil, int64(1)},
+		},
+		{
+			name:   "every problem reported by field",
+			input:  map[string]interface{}{"qty": "many", "extra": "x"},
+			strict: true,
+			wantFields: []fieldError{
+				{Field: "extra", Code: fieldUnknown},
+				{Field: "name", Code: fieldRequired},
+				{Field: "qty", Code: fieldInvalidType},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := mapWriteRow(tt.input, headers, true, schema, tt.strict)
+			var got []fieldError
+			for _, f := range fieldErrors(err) {
+				got = append(got, fieldError{Field: f.Field, Code: f.Code})
+			}
+			if !reflect.DeepEqual(got, tt.wantFields) {
+				t.Fatalf("field errors = %v, want %v (err %v)", got, tt.wantFields, err)
+			}
+			if !reflect.DeepEqual(row, tt.wantRow) {
+				t.Errorf("row = %#v, want %#v", row, tt.wantRow)
+			}
+		})
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	schema := models.ColumnSchema{"status": {Type: models.ColumnString, Default: "open"}}
+	input := map[string]interface{}{"name": "a"}
+
+	got := withDefaults(input, schema)
+	if want := map[string]interface{}{"name": "a", "status": "open"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("withDefaults = %v, want %v", got, want)
+	}
+	if _, ok := input["status"]; ok {
+		t.Error("withDefaults modified its input")
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/worker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseWriteRows decodes a write body's data: a single object, or an array of
+// objects for a bulk write (bulk is true even for a one-element array)
+func parseWriteRows(raw json.RawMessage) (rows []map[string]interface{}, bulk bool, err error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &rows); err != nil {
+			return nil, true, fmt.Errorf("data must be an object or an array of objects")
+		}
+		if len(rows) == 0 {
+			return nil, true, fmt.Errorf("data must contain at least one row")
+		}
+		for i, row := range rows {
+			if row == nil {
+				return nil, true, fmt.Errorf("row %d is not an object", i)
+			}
+		}
+		return rows, true, nil
+	}
+
+	var row map[string]interface{}
+	if err := json.Unmarshal(trimmed, &row); err != nil || row == nil {
+		return nil, false, fmt.Errorf("data must be an object or an array of objects")
+	}
+	return []map[string]interface{}{row}, false, nil
+}
+
+// mapWriteRow validates one inserted row and lays it out as a sheet row.
+// Declared defaults fill absent fields. With a header row every required
+// column must be present, and strict rejects fields that are not columns;
+// headerless sheets take column letters or indexes and leave missing columns
+// empty. Field problems are reported together as a *validationError.
+func mapWriteRow(input map[string]interface{}, headers []interface{}, useHeader bool, schema models.ColumnSchema, strict bool) ([]interface{}, error) {
+	if !useHeader {
+		data, err := positionalData(input)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = prepareWriteData(withDefaults(data, schema), schema); err != nil {
+			return nil, err
+		}
+		return mapRawRow(data, 0), nil
+	}
+
+	// Validate values against the declared column types
+	problems := &validationError{}
+	data, err := prepareWriteData(withDefaults(input, schema), schema)
+	if err != nil && !problems.merge(err) {
+		return nil, err
+	}
+	if strict {
+		checkUnknownFields(problems, input, headers)
+	}
+	row, err := validateAndMap(headers, data, schema)
+	if err != nil && !problems.merge(err) {
+		return nil, err
+	}
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// rowObject maps sheet row values to an object keyed by the headers
+func rowObject(headers []interface{}, values []interface{}) map[string]interface{} {
+	obj := make(map[string]interface{}, len(headers))
+	for i, h := range headers {
+		headerStr := fmt.Sprintf("%v", h)
+		if i < len(values) {
+			obj[headerStr] = values[i]
+		} else {
+			obj[headerStr] = nil
+		}
+	}
+	return obj
+}
+
+// pickFields returns only the requested fields of a row (all when none are given)
+func pickFields(row map[string]interface{}, fields []string) map[string]interface{} {
+	if len(fields) == 0 {
+		return row
+	}
+	picked := make(map[string]interface{}, len(fields))
+	for _, k := range fields {
+		picked[k] = row[k]
+	}
+	return picked
+}
+
+// tooManyBulkRows answers 400 when a bulk write exceeds the plan's row cap
+func tooManyBulkRows(c *gin.Context, limits models.PlanLimits, rows int) bool {
+	if limits.MaxBulkRows <= 0 || rows <= limits.MaxBulkRows {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "too many rows",
+		"details": fmt.Sprintf("your plan allows at most %d rows per bulk write", limits.MaxBulkRows),
+	})
+	return true
+}
+
+// exceedsQuota answers 429 when a write charged units quota units does not fit
+// in what remains of the owner's daily or monthly update quota
+func exceedsQuota(c *gin.Context, units int) bool {
+	remaining, ok := middleware.QuotaRemaining(c)
+	if !ok || units <= remaining {
+		return false
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":   "Quota exceeded",
+		"message": fmt.Sprintf("This write needs %d update units but only %d remain in your quota.", units, remaining),
+	})
+	return true
+}
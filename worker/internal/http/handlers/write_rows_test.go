@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseWriteRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		wantRows []map[string]interface{}
+		wantBulk bool
+		wantErr  bool
+	}{
+		{"object", `{"name":"a"}`, []map[string]interface{}{{"name": "a"}}, false, false},
+		{"array", ` [{"name":"a"},{"name":"b"}]`, []map[string]interface{}{{"name": "a"}, {"name": "b"}}, true, false},
+		{"one-element array", `[{"name":"a"}]`, []map[string]interface{}{{"name": "a"}}, true, false},
+		{"empty array", `[]`, nil, true, true},
+		{"array of scalars", `[1,2]`, nil, true, true},
+		{"null row", `[{"name":"a"},null]`, nil, true, true},
+		{"null", `null`, nil, false, true},
+		{"string", `"a"`, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, bulk, err := parseWriteRows(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWriteRows(%s) err = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if bulk != tt.wantBulk {
+				t.Errorf("bulk = %v, want %v", bulk, tt.wantBulk)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %v, want %v", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestBulkWriteLimits(t *testing.T) {
+	limits := models.PlanLimits{MaxBulkRows: 100}
+
+	tests := []struct {
+		name       string
+		rows       int
+		units      int
+		remaining  interface{} // quota units left, nil when not enforced
+		wantStatus int
+	}{
+		{"within limits", 100, 10, 10, http.StatusOK},
+		{"too many rows", 101, 1, 10, http.StatusBadRequest},
+		{"over remaining quota", 10, 11, 10, http.StatusTooManyRequests},
+		{"quota not enforced", 10, 11, nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			if tt.remaining != nil {
+				c.Set("quota_remaining", tt.remaining)
+			}
+			if !tooManyBulkRows(c, limits, tt.rows) && !exceedsQuota(c, tt.units) {
+				c.Status(http.StatusOK)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
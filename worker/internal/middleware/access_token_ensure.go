@@ -10,22 +10,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AccessTokenEnsureMiddleware ensures a valid Google access token for API requests using api_key
-func AccessTokenEnsureMiddleware(sheetRepo repository.AllowedSheetRepo, userRepo repository.UserRepo, authService *services.AuthService, clientId, clientSecret string) gin.HandlerFunc {
+// AccessTokenEnsureMiddleware ensures a valid Google access token for the owner
+// of the sheet resolved by SheetAuthMiddleware
+func AccessTokenEnsureMiddleware(userRepo repository.UserRepo, authService *services.AuthService, clientId, clientSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		apiKey := c.Param("api_key")
-		if apiKey == "" {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "missing api_key"})
+		sheet, ok := SheetFromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "sheet not resolved"})
 			return
 		}
 
 		ctx := c.Request.Context()
-		sheet, err := sheetRepo.FindByAPIKey(ctx, apiKey)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api_key"})
-			return
-		}
-
 		user, err := userRepo.FindByID(ctx, sheet.UserID)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"gsheetbase/worker/internal/cache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// idempotencyLockTTL bounds how long an in-flight request holds its key, so a
+// crashed request doesn't block retries for the whole window
+const idempotencyLockTTL = 2 * time.Minute
+
+// maxIdempotencyKeyLength caps the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// idempotentHeaders are the response headers replayed for a repeated key
+var idempotentHeaders = []string{"Content-Type", "ETag"}
+
+// IdempotencyMiddleware makes writes carrying an Idempotency-Key safe to retry.
+//
+// The first request with a key runs normally and its response is stored for
+// window; repeats within the window get that response back with
+// Idempotent-Replayed: true instead of writing again, and are not charged as
+// usage. Keys are scoped per sheet and per credential, so a key cannot replay
+// a response to a caller other than the one that made the request. A repeat
+// whose method, URL or body differs is rejected with 422, and one that arrives
+// while the first is still running gets 409. Server errors are not stored, so
+// those requests can be retried.
+func IdempotencyMiddleware(store cache.IdempotencyStore, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" || c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+
+		sheetIDRaw, _ := c.Get("sheet_id")
+		sheetID, ok := sheetIDRaw.(uuid.UUID)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		credential := requestCredential(c)
+		key = credential + ":" + key
+		fingerprint := requestFingerprint(c, credential, body)
+
+		ctx := c.Request.Context()
+		record, reserved, err := store.Reserve(ctx, sheetID, key, fingerprint, idempotencyLockTTL)
+		if err != nil {
+			// Don't block the write; it just isn't protected against retries
+			log.Printf("Idempotency key lookup failed: %v", err)
+			c.Next()
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":   "Idempotency-Key reused",
+					"details": "this key was already used with a different request; use a new key",
+				})
+			case record.Response == nil:
+				c.JSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			default:
+				for name, value := range record.Response.Headers {
+					c.Header(name, value)
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Set("usage_units", 0)
+				c.Data(record.Response.Status, record.Response.Headers["Content-Type"], record.Response.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		writer := &responseCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := store.Release(ctx, sheetID, key); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+			return
+		}
+
+		headers := make(map[string]string)
+		for _, name := range idempotentHeaders {
+			if value := writer.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		resp := &cache.CachedResponse{
+			Status:  writer.Status(),
+			Headers: headers,
+			Body:    writer.body.Bytes(),
+		}
+		if err := store.Complete(ctx, sheetID, key, fingerprint, resp, window); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	}
+}
+
+// requestCredential identifies the credential a request was made with: a
+// digest of the api_key in the path and the Authorization header
+func requestCredential(c *gin.Context) string {
+	sum := sha256.Sum256([]byte(c.Param("api_key") + "\n" + c.GetHeader("Authorization")))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestFingerprint hashes what must match for a key to be replayed
+func requestFingerprint(c *gin.Context, credential string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(credential + "\n" + c.Request.Method + "\n" + c.Request.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gsheetbase/worker/internal/cache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeIdempotencyStore keeps records in memory, keyed like the Redis store
+type fakeIdempotencyStore struct {
+	records  map[string]*cache.IdempotencyRecord
+	released int
+}
+
+func (s *fakeIdempotencyStore) Reserve(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, lockTTL time.Duration) (*cache.IdempotencyRecord, bool, error) {
+	if record, ok := s.records[sheetID.String()+key]; ok {
+		return record, false, nil
+	}
+	s.records[sheetID.String()+key] = &cache.IdempotencyRecord{Fingerprint: fingerprint}
+	return nil, true, nil
+}
+
+func (s *fakeIdempotencyStore) Complete(ctx context.Context, sheetID uuid.UUID, key, fingerprint string, resp *cache.CachedResponse, ttl time.Duration) error {
+	s.records[sheetID.String()+key] = &cache.IdempotencyRecord{Fingerprint: fingerprint, Response: resp}
+	return nil
+}
+
+func (s *fakeIdempotencyStore) Release(ctx context.Context, sheetID uuid.UUID, key string) error {
+	delete(s.records, sheetID.String()+key)
+	s.released++
+	return nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &fakeIdempotencyStore{records: map[string]*cache.IdempotencyRecord{}}
+	sheetID := uuid.New()
+
+	var r *gin.Engine
+	writes := 0
+	nestedStatus := 0
+	var units interface{}
+	r = gin.New()
+	r.POST("/v1/:api_key", func(c *gin.Context) {
+		c.Set("sheet_id", sheetID)
+		c.Next()
+		units, _ = c.Get("usage_units")
+	}, IdempotencyMiddleware(store, time.Hour), func(c *gin.Context) {
+		writes++
+		switch c.Query("case") {
+		case "nested":
+			// The same request arriving while this one is still running
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, idempotentRequest("/v1/key?case=nested", "k-nested", "", `{}`))
+			nestedStatus = w.Code
+		case "fail":
+			c.JSON(http.StatusBadGateway, gin.H{"error": "sheets unavailable"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"write": writes})
+	})
+
+	serve := func(path, key, authorization, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, idempotentRequest(path, key, authorization, body))
+		return w
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		key           string
+		authorization string
+		body          string
+		wantStatus    int
+		wantReplayed  bool
+		wantWrites    int
+	}{
+		{"first request", "/v1/key", "k-1", "", `{"a":1}`, http.StatusCreated, false, 1},
+		{"repeat is replayed", "/v1/key", "k-1", "", `{"a":1}`, http.StatusCreated, true, 1},
+		{"other body", "/v1/key", "k-1", "", `{"a":2}`, http.StatusUnprocessableEntity, false, 1},
+		{"other credential", "/v1/key", "k-1", "Bearer other-token", `{"a":1}`, http.StatusCreated, false, 2},
+		{"other api_key", "/v1/other-key", "k-1", "", `{"a":1}`, http.StatusCreated, false, 3},
+		{"server error", "/v1/key?case=fail", "k-2", "", `{}`, http.StatusBadGateway, false, 4},
+		{"retry after server error", "/v1/key?case=fail", "k-2", "", `{}`, http.StatusBadGateway, false, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.path, tt.key, tt.authorization, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantReplayed && units != 0 {
+				t.Errorf("usage_units = %v on a replay, want 0", units)
+			}
+			if !tt.wantReplayed && units != nil {
+				t.Errorf("usage_units = %v, want unset", units)
+			}
+			if writes != tt.wantWrites {
+				t.Errorf("writes = %d, want %d", writes, tt.wantWrites)
+			}
+		})
+	}
+	if store.released != 2 {
+		t.Errorf("released = %d, want 2", store.released)
+	}
+
+	t.Run("in progress", func(t *testing.T) {
+		w := serve("/v1/key?case=nested", "k-nested", "", `{}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+		}
+		if nestedStatus != http.StatusConflict {
+			t.Errorf("concurrent repeat status = %d, want %d", nestedStatus, http.StatusConflict)
+		}
+	})
+}
+
+func idempotentRequest(path, key, authorization, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return req
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gsheetbase/shared/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreflightOriginFunc decides which origins the CORS middleware accepts. A
+// preflight (OPTIONS) request addressed by api_key is checked against the
+// allowed origins of that sheet, so only permitted origins are reflected.
+// Other requests are let through: SheetOriginMiddleware checks them once the
+// sheet is resolved, including sheets addressed by Authorization header,
+// which a preflight cannot carry.
+func PreflightOriginFunc(sheetRepo repository.AllowedSheetRepo, apiKeyRepo repository.APIKeyRepo) func(c *gin.Context, origin string) bool {
+	return func(c *gin.Context, origin string) bool {
+		if c.Request.Method != http.MethodOptions {
+			return true
+		}
+
+		apiKey := c.Param("api_key")
+		if apiKey == "" {
+			return true
+		}
+
+		ctx := c.Request.Context()
+		sheet, err := sheetRepo.FindByAPIKey(ctx, apiKey)
+		if err != nil {
+			key, err := apiKeyRepo.FindByKey(ctx, apiKey)
+			if err != nil {
+				// Unknown key: the request itself will be refused
+				return true
+			}
+			if sheet, err = sheetRepo.FindByID(ctx, key.SheetID); err != nil {
+				return true
+			}
+		}
+		return sheet.AllowsOrigin(origin)
+	}
+}
+
+// SheetOriginMiddleware enforces the allowed origins and referers of the sheet
+// resolved by SheetAuthMiddleware. A browser request from an origin the sheet
+// does not allow gets 403 without CORS headers; once referers are configured,
+// browser requests must send a Referer matching one of them. Server-side
+// clients, which send neither Origin nor Referer, are not restricted.
+func SheetOriginMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sheet, ok := SheetFromContext(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		origin := c.GetHeader("Origin")
+		if origin != "" && !sheet.AllowsOrigin(origin) {
+			refuseOrigin(c, "origin not allowed")
+			return
+		}
+
+		referer := c.GetHeader("Referer")
+		if (origin != "" || referer != "") && !sheet.AllowsReferer(referer) {
+			refuseOrigin(c, "referer not allowed")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// refuseOrigin aborts with 403, dropping the origin the CORS middleware
+// reflected so the refused site cannot read the response
+func refuseOrigin(c *gin.Context, errMsg string) {
+	c.Writer.Header().Del("Access-Control-Allow-Origin")
+	c.JSON(http.StatusForbidden, gin.H{"error": errMsg})
+	c.Abort()
+}
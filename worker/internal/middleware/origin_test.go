@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSheetOriginMiddleware(t *testing.T) {
+	sheet := models.AllowedSheet{
+		AllowedOrigins:  []string{"https://example.com"},
+		AllowedReferers: []string{"https://example.com/app"},
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		referer string
+		want    int
+	}{
+		{"server request", "", "", http.StatusOK},
+		{"allowed origin and referer", "https://example.com", "https://example.com/app/page", http.StatusOK},
+		{"other origin", "https://evil.com", "https://example.com/app", http.StatusForbidden},
+		{"browser without referer", "https://example.com", "", http.StatusForbidden},
+		{"referer outside the path", "https://example.com", "https://example.com/admin", http.StatusForbidden},
+		{"referer only", "", "https://evil.com/app", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.GET("/v1/key", func(c *gin.Context) {
+				setSheet(c, sheet)
+				c.Header("Access-Control-Allow-Origin", tt.origin)
+			}, SheetOriginMiddleware(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/key", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusForbidden && w.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Error("refused response kept Access-Control-Allow-Origin")
+			}
+		})
+	}
+}
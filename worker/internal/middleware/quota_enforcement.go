@@ -12,38 +12,31 @@ import (
 )
 
 // QuotaEnforcementMiddleware creates a middleware that enforces both rate limits and quotas
+// for the sheet resolved by SheetAuthMiddleware.
 // It checks:
 // 1. Per-minute rate limits (GET vs UPDATE)
 // 2. Daily quotas (for UPDATE operations)
 // 3. Monthly quotas (GET and UPDATE separately)
+//
+// A write is let through while any quota remains; what is left is stored in
+// the context (see QuotaRemaining) so handlers can refuse a bulk write that
+// would overrun it.
 func QuotaEnforcementMiddleware(
 	rateLimitService *services.RateLimitService,
 	usageRepo repository.UsageRepo,
 	userRepo repository.UserRepo,
-	sheetRepo repository.AllowedSheetRepo,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		apiKey := c.Param("api_key")
-		if apiKey == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "API key is required"})
+		// The sheet was resolved by SheetAuthMiddleware (api_key or Authorization header)
+		sheet, ok := SheetFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "sheet not resolved"})
 			c.Abort()
 			return
 		}
 
 		httpMethod := c.Request.Method
 
-		// First, find the sheet by API key to get the user ID
-		sheet, err := sheetRepo.FindByAPIKey(c.Request.Context(), apiKey)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid API key or sheet not found"})
-			c.Abort()
-			return
-		}
-
-		// Set context values for downstream handlers
-		c.Set("sheet_id", sheet.ID)
-		c.Set("user_id", sheet.UserID)
-
 		// Get user from database to check subscription plan
 		user, err := userRepo.FindByID(c.Request.Context(), sheet.UserID)
 		if err != nil {
@@ -74,7 +67,7 @@ func QuotaEnforcementMiddleware(
 
 		// --- 1. Check per-minute rate limit ---
 		effectiveRateLimit := planLimits.GetEffectiveRateLimit(httpMethod)
-		rateLimitResult, err := rateLimitService.CheckLimit(c.Request.Context(), apiKey, httpMethod, effectiveRateLimit)
+		rateLimitResult, err := rateLimitService.CheckLimit(c.Request.Context(), usageKey(sheet), httpMethod, effectiveRateLimit)
 		if err != nil {
 			// Log error but don't block request on rate limit check failure
 			c.Next()
@@ -96,6 +89,9 @@ func QuotaEnforcementMiddleware(
 			return
 		}
 
+		// Quota units still available to a write, the lower of daily and monthly
+		remaining := -1
+
 		// --- 2. Check daily quota (only for write operations) ---
 		if isWrite && planLimits.DailyUpdateQuota > 0 {
 			dailyCount, err := usageRepo.GetTodayUsageCount(c.Request.Context(), sheet.UserID, methodCategory)
@@ -116,6 +112,7 @@ func QuotaEnforcementMiddleware(
 				c.Abort()
 				return
 			}
+			remaining = planLimits.DailyUpdateQuota - dailyCount
 		}
 
 		// --- 3. Check monthly quota ---
@@ -146,9 +143,30 @@ func QuotaEnforcementMiddleware(
 				c.Abort()
 				return
 			}
+			if isWrite && (remaining < 0 || monthlyQuota-monthlyCount < remaining) {
+				remaining = monthlyQuota - monthlyCount
+			}
+		}
+
+		if remaining >= 0 {
+			c.Set(quotaRemainingContextKey, remaining)
 		}
 
 		// All checks passed
 		c.Next()
 	}
 }
+
+// quotaRemainingContextKey holds the update quota units left for a write
+const quotaRemainingContextKey = "quota_remaining"
+
+// QuotaRemaining returns the update quota units left before the request, the
+// lower of the daily and monthly quota. It is false when no quota applies.
+func QuotaRemaining(c *gin.Context) (int, bool) {
+	remaining, ok := c.Get(quotaRemainingContextKey)
+	if !ok {
+		return 0, false
+	}
+	n, ok := remaining.(int)
+	return n, ok
+}
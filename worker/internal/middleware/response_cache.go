@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/shared/repository"
+	"gsheetbase/worker/internal/cache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// cachedHeaders are the response headers replayed on a cache hit
+var cachedHeaders = []string{"Content-Type", "Content-Disposition", "Link", "ETag", "Last-Modified", "X-Row-Version"}
+
+// responseCaptureWriter copies the response body so it can be cached
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ResponseCacheMiddleware serves GET responses from a read-through cache and
+// invalidates the sheet's cached responses after a successful write.
+//
+// The TTL is defaultTTL clamped up to the owner's plan CacheMinTTL. Plans with
+// CacheBypass may send nocache=1 to skip the lookup (the fresh response is
+// still stored). Responses carry X-Cache: HIT or MISS. A hit keeps the
+// Last-Modified time of the original read and is answered with 304 Not
+// Modified when its ETag matches If-None-Match or, without one, when it was
+// read no later than If-Modified-Since.
+func ResponseCacheMiddleware(responseCache cache.ResponseCache, userRepo repository.UserRepo, defaultTTL time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sheetIDRaw, _ := c.Get("sheet_id")
+		sheetID, ok := sheetIDRaw.(uuid.UUID)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		// Writes: run the handler, then drop cached reads of this sheet
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			if c.Writer.Status() >= 200 && c.Writer.Status() < 300 {
+				if err := responseCache.Invalidate(ctx, sheetID); err != nil {
+					log.Printf("Failed to invalidate response cache for sheet %s: %v", sheetID, err)
+				}
+			}
+			return
+		}
+
+		// The cache key does not cover the credential: a named API key without
+		// the read scope goes to the handler, which refuses it, instead of
+		// being served another caller's response
+		if !keyMayRead(c) {
+			c.Next()
+			return
+		}
+
+		userIDRaw, _ := c.Get("user_id")
+		userID, ok := userIDRaw.(uuid.UUID)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		user, err := userRepo.FindByID(ctx, userID)
+		if err != nil {
+			// Don't block the request; just serve it uncached
+			c.Next()
+			return
+		}
+
+		limits := user.GetPlanLimits()
+		ttl := limits.GetEffectiveCacheTTL(int(defaultTTL.Seconds()))
+		if ttl <= 0 {
+			c.Next()
+			return
+		}
+
+		key := responseCacheKey(c)
+		bypass := c.Query("nocache") == "1" && limits.CacheBypass
+
+		if !bypass {
+			cached, hit, err := responseCache.Get(ctx, sheetID, key)
+			if err != nil {
+				log.Printf("Response cache lookup failed: %v", err)
+			} else if hit {
+				for name, value := range cached.Headers {
+					c.Header(name, value)
+				}
+				c.Header("X-Cache", "HIT")
+				if cache.NotModified(c.Request.Header, cached.Headers["ETag"], cached.Headers["Last-Modified"]) {
+					c.Set("usage_weight", limits.NotModifiedWeight)
+					c.AbortWithStatus(http.StatusNotModified)
+					return
+				}
+				c.Data(cached.Status, cached.Headers["Content-Type"], cached.Body)
+				c.Abort()
+				return
+			}
+		}
+
+		c.Header("X-Cache", "MISS")
+		writer := &responseCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if writer.Status() != http.StatusOK {
+			return
+		}
+
+		headers := make(map[string]string)
+		for _, name := range cachedHeaders {
+			if value := writer.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		resp := &cache.CachedResponse{
+			Status:  writer.Status(),
+			Headers: headers,
+			Body:    writer.body.Bytes(),
+		}
+		if err := responseCache.Set(ctx, sheetID, key, resp, time.Duration(ttl)*time.Second); err != nil {
+			log.Printf("Failed to store response in cache: %v", err)
+		}
+	}
+}
+
+// responseCacheKey builds the cache key from the collection, the route, the
+// normalized query string (parameters sorted by name, nocache removed) and the
+// Accept header, which can select the response format
+func responseCacheKey(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	query.Del("nocache")
+	sum := sha256.Sum256([]byte(c.FullPath() + "\n" + query.Encode() + "\n" + c.GetHeader("Accept")))
+	return fmt.Sprintf("%s:%x", c.Query("collection"), sum)
+}
+
+// keyMayRead reports whether the named API key used for the request, if any,
+// has the read scope on the collection a GET reads: the collection parameter,
+// else the sheet's default range, else Sheet1
+func keyMayRead(c *gin.Context) bool {
+	key, ok := APIKeyFromContext(c)
+	if !ok {
+		return true
+	}
+	sheet, _ := SheetFromContext(c)
+	readRange := c.Query("collection")
+	if readRange == "" && sheet.DefaultRange != nil {
+		readRange = *sheet.DefaultRange
+	}
+	if readRange == "" {
+		readRange = "Sheet1"
+	}
+	collection, _, _ := strings.Cut(readRange, "!")
+	return key.Allows(models.ScopeRead, strings.Trim(collection, "'"))
+}
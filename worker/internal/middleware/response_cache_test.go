@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	keyFor := func(query string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/v1/key"+query, nil)
+		return responseCacheKey(c)
+	}
+	base := keyFor("?collection=Orders&limit=10&orderBy=name")
+
+	tests := []struct {
+		name  string
+		query string
+		same  bool
+	}{
+		{"parameter order", "?orderBy=name&limit=10&collection=Orders", true},
+		{"nocache", "?collection=Orders&limit=10&orderBy=name&nocache=1", true},
+		{"other limit", "?collection=Orders&limit=20&orderBy=name", false},
+		{"other collection", "?collection=Users&limit=10&orderBy=name", false},
+		{"no parameters", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keyFor(tt.query) == base; got != tt.same {
+				t.Errorf("key for %q equal to base = %v, want %v", tt.query, got, tt.same)
+			}
+		})
+	}
+
+	if !strings.HasPrefix(base, "Orders:") {
+		t.Errorf("key %q is not prefixed with its collection", base)
+	}
+}
+
+func TestKeyMayRead(t *testing.T) {
+	defaultRange := "'Open Orders'!A1:D"
+
+	tests := []struct {
+		name   string
+		scopes []string
+		query  string
+		want   bool
+	}{
+		{"read scope", []string{models.ScopeRead}, "?collection=Orders", true},
+		{"read on that collection", []string{"read:Orders"}, "?collection=Orders!A1:C", true},
+		{"read on another collection", []string{"read:Users"}, "?collection=Orders", false},
+		{"write only", []string{models.ScopeWrite}, "?collection=Orders", false},
+		{"default range", []string{"read:Open Orders"}, "", true},
+		{"default range without scope", []string{"read:Orders"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/key"+tt.query, nil)
+			setSheet(c, models.AllowedSheet{ID: uuid.New(), DefaultRange: &defaultRange})
+			c.Set(apiKeyContextKey, models.APIKey{Scopes: tt.scopes})
+
+			if got := keyMayRead(c); got != tt.want {
+				t.Errorf("keyMayRead = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/key", nil)
+	if !keyMayRead(c) {
+		t.Error("keyMayRead refused a request without a named key")
+	}
+}
@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"encoding/base64"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"gsheetbase/shared/models"
 	"gsheetbase/shared/repository"
@@ -16,103 +18,174 @@ import (
 // 2. Bearer token: Authorization: Bearer <token>
 // 3. Basic auth: Authorization: Basic <base64(username:password)>
 //
-// For auth_type = 'none', only allows access if is_public = true.
-// Sets sheet_id and user_id in context for downstream handlers.
-func SheetAuthMiddleware(sheetRepo repository.AllowedSheetRepo) gin.HandlerFunc {
+// A named API key (api_keys table) is accepted in place of the api_key path
+// segment or as a Bearer token on sheets with auth_type = 'none'; a sheet
+// protected by bearer or basic auth refuses it so the key cannot stand in for
+// those credentials. The key is stored in the context (see APIKeyFromContext)
+// so its scopes can be enforced.
+//
+// For auth_type = 'none', only allows access if is_public = true. A sheet with
+// another auth type addressed by api_key must also send credentials for that
+// same sheet; without an api_key the credentials alone select the sheet.
+// The resolved sheet is stored in the context (see SheetFromContext) together
+// with sheet_id and user_id for downstream middlewares and handlers.
+func SheetAuthMiddleware(sheetRepo repository.AllowedSheetRepo, apiKeyRepo repository.APIKeyRepo) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		apiKey := c.Param("api_key")
 		authHeader := c.GetHeader("Authorization")
 
-		var sheet models.AllowedSheet
-		var err error
-
 		// Try API key first (backward compatibility)
+		var keySheet *models.AllowedSheet
 		if apiKey != "" {
-			sheet, err = sheetRepo.FindByAPIKey(c.Request.Context(), apiKey)
-			if err == nil && sheet.AuthType == "none" {
-				// API key found and is_public=true (enforced by FindByAPIKey)
-				c.Set("sheet_id", sheet.ID)
-				c.Set("user_id", sheet.UserID)
+			sheet, err := sheetRepo.FindByAPIKey(c.Request.Context(), apiKey)
+			if err != nil {
+				// Not a sheet's own API key: try the named API keys
+				sheet, key, errMsg := sheetFromNamedKey(c, sheetRepo, apiKeyRepo, apiKey)
+				if errMsg != "" {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+					c.Abort()
+					return
+				}
+				setSheet(c, sheet)
+				c.Set(apiKeyContextKey, key)
 				c.Next()
 				return
 			}
 
-			if err != nil {
-				// API key provided but not found
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid api_key"})
-				c.Abort()
+			if sheet.AuthType == "none" {
+				// API key found and is_public=true (enforced by FindByAPIKey)
+				setSheet(c, sheet)
+				c.Next()
 				return
 			}
+			keySheet = &sheet
+		}
 
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing api_key"})
+		// Protected sheet or no API key: the Authorization header is required
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing Authorization header"})
 			c.Abort()
 			return
 		}
 
-		// No API key: try Authorization header
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing Authorization header"})
+		sheet, key, errMsg := sheetFromAuthHeader(c, sheetRepo, apiKeyRepo, authHeader)
+		if errMsg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
 			c.Abort()
 			return
 		}
 
-		// Parse Authorization header: "Bearer <token>" or "Basic <base64>"
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid Authorization header format"})
+		if keySheet != nil && keySheet.ID != sheet.ID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "credentials do not match api_key"})
 			c.Abort()
 			return
 		}
 
-		scheme := parts[0]
-		credentials := parts[1]
+		// Successfully resolved sheet
+		setSheet(c, sheet)
+		if key != nil {
+			c.Set(apiKeyContextKey, *key)
+		}
+		c.Next()
+	}
+}
 
-		switch scheme {
-		case "Bearer":
-			// Bearer token authentication
-			sheet, err = sheetRepo.FindByBearerToken(c.Request.Context(), credentials)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid bearer token"})
-				c.Abort()
-				return
-			}
+// sheetFromAuthHeader resolves the sheet for an Authorization header, and the
+// named API key when the Bearer token is one. It returns an error message when
+// the header is malformed or the credentials are wrong.
+func sheetFromAuthHeader(c *gin.Context, sheetRepo repository.AllowedSheetRepo, apiKeyRepo repository.APIKeyRepo, authHeader string) (models.AllowedSheet, *models.APIKey, string) {
+	// Parse Authorization header: "Bearer <token>" or "Basic <base64>"
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 {
+		return models.AllowedSheet{}, nil, "invalid Authorization header format"
+	}
 
-		case "Basic":
-			// Basic authentication: decode base64(username:password)
-			decoded, err := base64.StdEncoding.DecodeString(credentials)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid Basic auth encoding"})
-				c.Abort()
-				return
-			}
+	scheme := parts[0]
+	credentials := parts[1]
 
-			userPass := strings.SplitN(string(decoded), ":", 2)
-			if len(userPass) != 2 {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid Basic auth format"})
-				c.Abort()
-				return
-			}
+	switch scheme {
+	case "Bearer":
+		// Bearer token authentication
+		sheet, err := sheetRepo.FindByBearerToken(c.Request.Context(), credentials)
+		if err == nil {
+			reportBearerToken(c, sheet, credentials)
+			return sheet, nil, ""
+		}
 
-			username := userPass[0]
-			password := userPass[1]
+		// Not the sheet's bearer token: try the named API keys
+		sheet, key, errMsg := sheetFromNamedKey(c, sheetRepo, apiKeyRepo, credentials)
+		if errMsg == "invalid api_key" {
+			return models.AllowedSheet{}, nil, "invalid bearer token"
+		}
+		if errMsg != "" {
+			return models.AllowedSheet{}, nil, errMsg
+		}
+		return sheet, &key, ""
 
-			sheet, err = sheetRepo.FindByBasicCredentials(c.Request.Context(), username, password)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
-				c.Abort()
-				return
-			}
+	case "Basic":
+		// Basic authentication: decode base64(username:password)
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return models.AllowedSheet{}, nil, "invalid Basic auth encoding"
+		}
 
-		default:
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unsupported Authorization scheme; use Bearer or Basic"})
-			c.Abort()
-			return
+		userPass := strings.SplitN(string(decoded), ":", 2)
+		if len(userPass) != 2 {
+			return models.AllowedSheet{}, nil, "invalid Basic auth format"
 		}
 
-		// Successfully resolved sheet
-		c.Set("sheet_id", sheet.ID)
-		c.Set("user_id", sheet.UserID)
-		c.Next()
+		username := userPass[0]
+		password := userPass[1]
+
+		sheet, err := sheetRepo.FindByBasicCredentials(c.Request.Context(), username, password)
+		if err != nil {
+			return models.AllowedSheet{}, nil, "invalid credentials"
+		}
+		return sheet, nil, ""
+
+	default:
+		return models.AllowedSheet{}, nil, "unsupported Authorization scheme; use Bearer or Basic"
+	}
+}
+
+// reportBearerToken tells the client whether it authenticated with the sheet's
+// current bearer token or with the one replaced by the last rotation, which
+// stops working at X-Auth-Token-Expires
+func reportBearerToken(c *gin.Context, sheet models.AllowedSheet, token string) {
+	if !sheet.IsPreviousBearerToken(repository.HashToken(token)) {
+		c.Header("X-Auth-Token-Used", "current")
+		return
+	}
+	c.Header("X-Auth-Token-Used", "previous")
+	if sheet.AuthPreviousBearerTokenExpiresAt != nil {
+		c.Header("X-Auth-Token-Expires", sheet.AuthPreviousBearerTokenExpiresAt.UTC().Format(time.RFC3339))
+	}
+}
+
+// sheetFromNamedKey resolves a named API key to its published sheet and
+// records that the key was used. It returns an error message when the key is
+// unknown, expired, or its sheet is not published or requires other auth.
+func sheetFromNamedKey(c *gin.Context, sheetRepo repository.AllowedSheetRepo, apiKeyRepo repository.APIKeyRepo, value string) (models.AllowedSheet, models.APIKey, string) {
+	ctx := c.Request.Context()
+
+	key, err := apiKeyRepo.FindByKey(ctx, value)
+	if err != nil {
+		return models.AllowedSheet{}, models.APIKey{}, "invalid api_key"
+	}
+	if key.IsExpired(time.Now()) {
+		return models.AllowedSheet{}, models.APIKey{}, "api key expired"
+	}
+
+	sheet, err := sheetRepo.FindByID(ctx, key.SheetID)
+	if err != nil || !sheet.IsPublic {
+		return models.AllowedSheet{}, models.APIKey{}, "invalid api_key"
+	}
+	if sheet.AuthType != "none" {
+		return models.AllowedSheet{}, models.APIKey{}, "api keys are not accepted by sheets that require bearer or basic auth"
+	}
+
+	if err := apiKeyRepo.TouchLastUsed(ctx, key.ID); err != nil {
+		log.Printf("Failed to record use of api key %s: %v", key.ID, err)
 	}
+	return sheet, key, ""
 }
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gsheetbase/shared/models"
+	"gsheetbase/shared/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeSheetRepo serves sheets from memory for the lookups SheetAuthMiddleware makes
+type fakeSheetRepo struct {
+	repository.AllowedSheetRepo
+	sheets []models.AllowedSheet
+	bearer map[string]uuid.UUID // bearer token → sheet ID
+}
+
+func (r *fakeSheetRepo) FindByID(ctx context.Context, id uuid.UUID) (models.AllowedSheet, error) {
+	for _, s := range r.sheets {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return models.AllowedSheet{}, sql.ErrNoRows
+}
+
+func (r *fakeSheetRepo) FindByAPIKey(ctx context.Context, apiKey string) (models.AllowedSheet, error) {
+	for _, s := range r.sheets {
+		if s.APIKey != nil && *s.APIKey == apiKey && s.IsPublic {
+			return s, nil
+		}
+	}
+	return models.AllowedSheet{}, sql.ErrNoRows
+}
+
+func (r *fakeSheetRepo) FindByBearerToken(ctx context.Context, token string) (models.AllowedSheet, error) {
+	if id, ok := r.bearer[token]; ok {
+		return r.FindByID(ctx, id)
+	}
+	return models.AllowedSheet{}, sql.ErrNoRows
+}
+
+// fakeAPIKeyRepo serves named API keys from memory, keyed by the raw key
+type fakeAPIKeyRepo struct {
+	repository.APIKeyRepo
+	keys map[string]models.APIKey
+}
+
+func (r *fakeAPIKeyRepo) FindByKey(ctx context.Context, key string) (models.APIKey, error) {
+	if k, ok := r.keys[key]; ok {
+		return k, nil
+	}
+	return models.APIKey{}, sql.ErrNoRows
+}
+
+func (r *fakeAPIKeyRepo) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+// authFixture holds a public sheet and a bearer-protected sheet, each with a
+// named key, and a second bearer-protected sheet
+type authFixture struct {
+	sheetRepo  *fakeSheetRepo
+	apiKeyRepo *fakeAPIKeyRepo
+	public     models.AllowedSheet
+	protected  models.AllowedSheet
+	other      models.AllowedSheet
+}
+
+func newAuthFixture() authFixture {
+	publicKey, protectedKey := "public-key", "protected-key"
+	public := models.AllowedSheet{ID: uuid.New(), APIKey: &publicKey, IsPublic: true, AuthType: "none"}
+	protected := models.AllowedSheet{ID: uuid.New(), APIKey: &protectedKey, IsPublic: true, AuthType: "bearer"}
+	other := models.AllowedSheet{ID: uuid.New(), IsPublic: true, AuthType: "bearer"}
+	expired := time.Now().Add(-time.Hour)
+
+	return authFixture{
+		sheetRepo: &fakeSheetRepo{
+			sheets: []models.AllowedSheet{public, protected, other},
+			bearer: map[string]uuid.UUID{"sheet-token": protected.ID, "other-token": other.ID},
+		},
+		apiKeyRepo: &fakeAPIKeyRepo{keys: map[string]models.APIKey{
+			"gskey_public":    {ID: uuid.New(), SheetID: public.ID, Scopes: []string{models.ScopeRead}},
+			"gskey_protected": {ID: uuid.New(), SheetID: protected.ID, Scopes: []string{models.ScopeRead}},
+			"gskey_expired":   {ID: uuid.New(), SheetID: public.ID, Scopes: []string{models.ScopeRead}, ExpiresAt: &expired},
+		}},
+		public:    public,
+		protected: protected,
+		other:     other,
+	}
+}
+
+// serveAuth runs a GET through SheetAuthMiddleware and returns the status,
+// the resolved sheet and the named key, if any
+func (f authFixture) serveAuth(path, authorization string) (int, *models.AllowedSheet, *models.APIKey) {
+	gin.SetMode(gin.TestMode)
+	var sheet *models.AllowedSheet
+	var key *models.APIKey
+	handler := func(c *gin.Context) {
+		if s, ok := SheetFromContext(c); ok {
+			sheet = &s
+		}
+		if k, ok := APIKeyFromContext(c); ok {
+			key = &k
+		}
+		c.Status(http.StatusOK)
+	}
+	r := gin.New()
+	auth := SheetAuthMiddleware(f.sheetRepo, f.apiKeyRepo)
+	r.GET("/v1/:api_key", auth, handler)
+	r.GET("/v1", auth, handler)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, sheet, key
+}
+
+func TestSheetAuthHeaderOnly(t *testing.T) {
+	f := newAuthFixture()
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantSheet     uuid.UUID
+	}{
+		{"bearer token without api_key", "/v1", "Bearer sheet-token", http.StatusOK, f.protected.ID},
+		{"other sheet's bearer token", "/v1", "Bearer other-token", http.StatusOK, f.other.ID},
+		{"api_key and matching token", "/v1/protected-key", "Bearer sheet-token", http.StatusOK, f.protected.ID},
+		{"api_key and another sheet's token", "/v1/protected-key", "Bearer other-token", http.StatusUnauthorized, uuid.Nil},
+		{"protected api_key alone", "/v1/protected-key", "", http.StatusUnauthorized, uuid.Nil},
+		{"no credentials", "/v1", "", http.StatusUnauthorized, uuid.Nil},
+		{"wrong token", "/v1", "Bearer wrong", http.StatusUnauthorized, uuid.Nil},
+		{"unsupported scheme", "/v1", "Token sheet-token", http.StatusUnauthorized, uuid.Nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, sheet, _ := f.serveAuth(tt.path, tt.authorization)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && (sheet == nil || sheet.ID != tt.wantSheet) {
+				t.Errorf("sheet = %v, want %s", sheet, tt.wantSheet)
+			}
+		})
+	}
+}
+
+func TestSheetAuthNamedKey(t *testing.T) {
+	f := newAuthFixture()
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantSheet     uuid.UUID
+		wantKey       bool
+	}{
+		{"key in path on public sheet", "/v1/gskey_public", "", http.StatusOK, f.public.ID, true},
+		{"key as bearer on public sheet", "/v1", "Bearer gskey_public", http.StatusOK, f.public.ID, true},
+		{"key in path on protected sheet", "/v1/gskey_protected", "", http.StatusUnauthorized, uuid.Nil, false},
+		{"key as bearer on protected sheet", "/v1", "Bearer gskey_protected", http.StatusUnauthorized, uuid.Nil, false},
+		{"expired key", "/v1/gskey_expired", "", http.StatusUnauthorized, uuid.Nil, false},
+		{"unknown key", "/v1/gskey_unknown", "", http.StatusUnauthorized, uuid.Nil, false},
+		{"sheet api key", "/v1/public-key", "", http.StatusOK, f.public.ID, false},
+		{"sheet bearer token", "/v1", "Bearer sheet-token", http.StatusOK, f.protected.ID, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, sheet, key := f.serveAuth(tt.path, tt.authorization)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if sheet == nil || sheet.ID != tt.wantSheet {
+				t.Errorf("sheet = %v, want %s", sheet, tt.wantSheet)
+			}
+			if (key != nil) != tt.wantKey {
+				t.Errorf("named key in context = %v, want %v", key != nil, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestUsageKeySharedByNamedKeys(t *testing.T) {
+	f := newAuthFixture()
+
+	_, viaSheetKey, _ := f.serveAuth("/v1/public-key", "")
+	_, viaNamedKey, _ := f.serveAuth("/v1/gskey_public", "")
+	if viaSheetKey == nil || viaNamedKey == nil {
+		t.Fatal("sheet not resolved")
+	}
+	if usageKey(*viaNamedKey) != usageKey(*viaSheetKey) {
+		t.Errorf("named key counts under %q, sheet key under %q", usageKey(*viaNamedKey), usageKey(*viaSheetKey))
+	}
+
+	unkeyed := models.AllowedSheet{ID: uuid.New()}
+	if got := usageKey(unkeyed); got != unkeyed.ID.String() {
+		t.Errorf("usageKey of a sheet without api_key = %q, want its ID", got)
+	}
+}
+
+func TestReportBearerToken(t *testing.T) {
+	current, previous := repository.HashToken("new-token"), repository.HashToken("old-token")
+	expires := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	sheet := models.AllowedSheet{
+		AuthBearerTokenDigest:            &current,
+		AuthPreviousBearerTokenDigest:    &previous,
+		AuthPreviousBearerTokenExpiresAt: &expires,
+	}
+
+	tests := []struct {
+		token       string
+		wantUsed    string
+		wantExpires string
+	}{
+		{"new-token", "current", ""},
+		{"old-token", "previous", "2026-10-17T12:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			reportBearerToken(c, sheet, tt.token)
+			if got := w.Header().Get("X-Auth-Token-Used"); got != tt.wantUsed {
+				t.Errorf("X-Auth-Token-Used = %q, want %q", got, tt.wantUsed)
+			}
+			if got := w.Header().Get("X-Auth-Token-Expires"); got != tt.wantExpires {
+				t.Errorf("X-Auth-Token-Expires = %q, want %q", got, tt.wantExpires)
+			}
+		})
+	}
+}
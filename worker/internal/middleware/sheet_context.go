@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"gsheetbase/shared/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys set by SheetAuthMiddleware
+const (
+	sheetContextKey  = "sheet"   // the resolved sheet
+	apiKeyContextKey = "api_key" // the named API key used, if any
+)
+
+// setSheet stores the resolved sheet in the context, along with the sheet_id
+// and user_id keys read by the usage, cache and idempotency middlewares
+func setSheet(c *gin.Context, sheet models.AllowedSheet) {
+	c.Set(sheetContextKey, sheet)
+	c.Set("sheet_id", sheet.ID)
+	c.Set("user_id", sheet.UserID)
+}
+
+// SheetFromContext returns the sheet SheetAuthMiddleware resolved for the
+// request, whether it was addressed by api_key or by Authorization header
+func SheetFromContext(c *gin.Context) (models.AllowedSheet, bool) {
+	raw, ok := c.Get(sheetContextKey)
+	if !ok {
+		return models.AllowedSheet{}, false
+	}
+	sheet, ok := raw.(models.AllowedSheet)
+	return sheet, ok
+}
+
+// APIKeyFromContext returns the named API key the request authenticated with.
+// It is false for requests using the sheet's own api_key or credentials.
+func APIKeyFromContext(c *gin.Context) (models.APIKey, bool) {
+	raw, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return models.APIKey{}, false
+	}
+	key, ok := raw.(models.APIKey)
+	return key, ok
+}
+
+// usageKey identifies the sheet in rate limits and usage records: its API key,
+// or its ID for a sheet that has none. Named API keys share their sheet's
+// limits, so minting more keys never raises them; usage is attributed to the
+// key separately (see namedKeyID).
+func usageKey(sheet models.AllowedSheet) string {
+	if sheet.APIKey != nil && *sheet.APIKey != "" {
+		return *sheet.APIKey
+	}
+	return sheet.ID.String()
+}
@@ -2,7 +2,10 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"sync"
 	"time"
 
 	"gsheetbase/shared/repository"
@@ -14,9 +17,11 @@ import (
 // UsageEvent represents a single API usage event
 type UsageEvent struct {
 	APIKey    string
+	APIKeyID  *uuid.UUID // named API key, nil for the sheet credentials
 	UserID    uuid.UUID
 	SheetID   uuid.UUID
 	Method    string
+	Count     int
 	Timestamp time.Time
 }
 
@@ -26,6 +31,10 @@ type UsageTracker struct {
 	eventChan   chan UsageEvent
 	stopChan    chan struct{}
 	workerCount int
+
+	// Fractional usage (percent of a unit) carried over per usage row
+	fractionMu sync.Mutex
+	fractions  map[string]int
 }
 
 // NewUsageTracker creates a new usage tracker with background workers
@@ -35,6 +44,7 @@ func NewUsageTracker(usageRepo repository.UsageRepo, workerCount int) *UsageTrac
 		eventChan:   make(chan UsageEvent, 10000),
 		stopChan:    make(chan struct{}),
 		workerCount: workerCount,
+		fractions:   make(map[string]int),
 	}
 
 	// Start background workers
@@ -55,10 +65,12 @@ func (t *UsageTracker) worker() {
 			err := t.usageRepo.IncrementDailyUsage(
 				ctx,
 				event.APIKey,
+				event.APIKeyID,
 				event.UserID,
 				event.SheetID,
 				event.Timestamp,
 				event.Method,
+				event.Count,
 			)
 			cancel()
 
@@ -72,13 +84,15 @@ func (t *UsageTracker) worker() {
 }
 
 // Track queues a usage event for async processing
-func (t *UsageTracker) Track(apiKey string, userID, sheetID uuid.UUID, method string) {
+func (t *UsageTracker) Track(apiKey string, apiKeyID *uuid.UUID, userID, sheetID uuid.UUID, method string, count int) {
 	select {
 	case t.eventChan <- UsageEvent{
 		APIKey:    apiKey,
+		APIKeyID:  apiKeyID,
 		UserID:    userID,
 		SheetID:   sheetID,
 		Method:    method,
+		Count:     count,
 		Timestamp: time.Now(),
 	}:
 	default:
@@ -86,20 +100,68 @@ func (t *UsageTracker) Track(apiKey string, userID, sheetID uuid.UUID, method st
 	}
 }
 
+// TrackWeighted records a fraction of a usage unit (percent of one request).
+// Fractions accumulate in memory and are tracked once they add up to whole units.
+func (t *UsageTracker) TrackWeighted(apiKey string, apiKeyID *uuid.UUID, userID, sheetID uuid.UUID, method string, percent int) {
+	if percent <= 0 {
+		return
+	}
+
+	key := fmt.Sprintf("%s:%s", apiKey, method)
+	if apiKeyID != nil {
+		key += ":" + apiKeyID.String()
+	}
+	t.fractionMu.Lock()
+	total := t.fractions[key] + percent
+	t.fractions[key] = total % 100
+	t.fractionMu.Unlock()
+
+	if units := total / 100; units > 0 {
+		t.Track(apiKey, apiKeyID, userID, sheetID, method, units)
+	}
+}
+
 // Shutdown gracefully stops the usage tracker
 func (t *UsageTracker) Shutdown() {
 	close(t.stopChan)
 	close(t.eventChan)
 }
 
-// UsageTrackingMiddleware creates a middleware that tracks API usage
+// namedKeyID returns the ID of the named API key used for the request, if any
+func namedKeyID(c *gin.Context) *uuid.UUID {
+	if key, ok := APIKeyFromContext(c); ok {
+		return &key.ID
+	}
+	return nil
+}
+
+// UsageTrackingMiddleware creates a middleware that tracks API usage.
+// Successful requests count as one unit, or as "usage_units" when it is set:
+// handlers charge more for bulk writes, and replayed responses charge nothing.
+// A 304 Not Modified counts as the percentage stored under "usage_weight" by
+// whoever answered it (none if unset). Usage is attributed to the named API key
+// when one was used.
 func UsageTrackingMiddleware(tracker *UsageTracker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
 
+		if c.Writer.Status() == http.StatusNotModified {
+			weight := c.GetInt("usage_weight")
+			sheetIDRaw, _ := c.Get("sheet_id")
+			userIDRaw, _ := c.Get("user_id")
+			sheetID, sheetOk := sheetIDRaw.(uuid.UUID)
+			userID, userOk := userIDRaw.(uuid.UUID)
+			sheet, _ := SheetFromContext(c)
+			if weight > 0 && sheetOk && userOk {
+				tracker.TrackWeighted(usageKey(sheet), namedKeyID(c), userID, sheetID, c.Request.Method, weight)
+			}
+			return
+		}
+
 		// Only track successful requests
 		if c.Writer.Status() >= 200 && c.Writer.Status() < 300 {
-			apiKey := c.Param("api_key")
+			sheet, _ := SheetFromContext(c)
+			apiKey := usageKey(sheet)
 			method := c.Request.Method
 
 			sheetIDRaw, sheetExists := c.Get("sheet_id")
@@ -110,7 +172,13 @@ func UsageTrackingMiddleware(tracker *UsageTracker) gin.HandlerFunc {
 				userID, userOk := userIDRaw.(uuid.UUID)
 
 				if sheetOk && userOk {
-					tracker.Track(apiKey, userID, sheetID, method)
+					units := 1
+					if _, set := c.Get("usage_units"); set {
+						units = c.GetInt("usage_units")
+					}
+					if units > 0 {
+						tracker.Track(apiKey, namedKeyID(c), userID, sheetID, method, units)
+					}
 				}
 			}
 		}